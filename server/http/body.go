@@ -0,0 +1,195 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync/atomic"
+)
+
+// A message body is an io.ReadCloser, so it can stream: a body read off a connection is read off the connection
+// as the handler asks for it, and a body being written can come from anywhere, e.g an *os.File.
+// Bodies are byte-exact; nothing is trimmed or re-encoded along the way.
+//
+// Bodies set with SetBody (or NewRequest, NewResponse) live in memory, so they can be written over and over:
+// String() and MarshalText() don't use them up. Any other body is used up and closed by WriteTo.
+
+// memBody is an in-memory body.
+type memBody struct {
+	b []byte
+	r bytes.Reader
+}
+
+func newMemBody(b []byte) *memBody {
+	mb := &memBody{b: b}
+	mb.r.Reset(b)
+	return mb
+}
+
+func (mb *memBody) Read(p []byte) (int, error) { return mb.r.Read(p) }
+func (mb *memBody) Close() error               { return nil }
+
+// SetBody replaces the body with b, setting ContentLength to match. It doesn't touch the headers.
+func (r *Request) SetBody(b []byte) { r.Body, r.ContentLength = newMemBody(b), int64(len(b)) }
+
+// SetBody replaces the body with b, setting ContentLength to match. It doesn't touch the headers.
+func (resp *Response) SetBody(b []byte) { resp.Body, resp.ContentLength = newMemBody(b), int64(len(b)) }
+
+// BodyBytes reads the whole body, exactly as it was sent. The body is replaced by an in-memory copy,
+// so BodyBytes can be called again, and the request can still be written afterwards.
+func (r *Request) BodyBytes() ([]byte, error) { return bodyBytes(&r.Body, &r.ContentLength) }
+
+// BodyBytes reads the whole body, exactly as it was sent. The body is replaced by an in-memory copy,
+// so BodyBytes can be called again, and the response can still be written afterwards.
+func (resp *Response) BodyBytes() ([]byte, error) { return bodyBytes(&resp.Body, &resp.ContentLength) }
+
+func bodyBytes(rc *io.ReadCloser, contentLength *int64) ([]byte, error) {
+	switch body := (*rc).(type) {
+	case nil:
+		return nil, nil
+	case *memBody:
+		return body.b, nil
+	}
+	defer (*rc).Close()
+	b, err := io.ReadAll(*rc)
+	if err != nil {
+		return nil, err
+	}
+	*rc, *contentLength = newMemBody(b), int64(len(b))
+	return b, nil
+}
+
+// bodyLength returns the length of body in bytes, or -1 if we can't know it without reading it.
+// Following net/http, a ContentLength of 0 with a non-nil body means "unknown", since it's the zero value.
+func bodyLength(body io.ReadCloser, contentLength int64) int64 {
+	switch body := body.(type) {
+	case nil:
+		return 0
+	case *memBody:
+		return int64(len(body.b))
+	}
+	if contentLength > 0 {
+		return contentLength
+	}
+	return -1
+}
+
+// writeBody writes body to w, chunked if the headers say so, and closes it.
+// trailer is a pointer so a trailer filled in while the body streams is still sent.
+func writeBody(w io.Writer, body io.ReadCloser, headers Headers, trailer *Headers) (n int64, err error) {
+	cw := &countingWriter{w: w}
+	var src io.Reader = body
+	switch b := body.(type) {
+	case nil:
+		src = new(bytes.Reader) // nothing to write, but a chunked body still needs its last chunk.
+	case *memBody:
+		src = bytes.NewReader(b.b) // leave b itself alone, so we can write it again.
+	default:
+		defer body.Close()
+	}
+	if !isChunked(headers) {
+		_, err = io.Copy(cw, src)
+		return cw.n, err
+	}
+	chunked := NewChunkedWriter(cw)
+	if _, err := io.Copy(chunked, src); err != nil {
+		return cw.n, err
+	}
+	chunked.Trailer = *trailer
+	err = chunked.Close()
+	return cw.n, err
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// errBodyClosed is returned by reading a body after closing it.
+var errBodyClosed = errors.New("http: read on closed body")
+
+// body streams a message body off the connection it's being read from, stopping where the framing says it ends.
+type body struct {
+	src       io.Reader // the msgReader itself, or a ChunkedReader on top of it
+	remaining int64     // bytes left, for Content-Length framing; -1 if src knows where the body ends (chunked or EOF)
+	max       int64     // most bytes we'll read before failing with ErrBodyTooLarge; -1 means no limit
+	read      int64     // bytes read so far
+	mr        *msgReader
+	onEOF     func() // called once, when the body's been read to the end; e.g to copy the trailer.
+	err       error  // sticky; io.EOF once we're done
+	closed    bool
+	tooLarge  int32 // set, atomically, once it's gone over max; see bodyTooLarge
+}
+
+func (b *body) Read(p []byte) (n int, err error) {
+	if b.closed {
+		return 0, errBodyClosed
+	}
+	return b.read1(p)
+}
+
+// Close stops the body from being read any further. It doesn't touch the connection:
+// the server skips over whatever's left of the body before reading the next request.
+func (b *body) Close() error {
+	b.closed = true
+	return nil
+}
+
+func (b *body) read1(p []byte) (n int, err error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if b.remaining == 0 {
+		return 0, b.finish(io.EOF)
+	}
+	if b.remaining > 0 && int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	if b.max >= 0 && int64(len(p)) > b.max-b.read+1 {
+		p = p[:b.max-b.read+1] // one byte over, so we can tell if there's more.
+	}
+	n, err = b.src.Read(p)
+	b.read += int64(n)
+	if b.remaining > 0 {
+		b.remaining -= int64(n)
+		if err == io.EOF && b.remaining > 0 {
+			err = io.ErrUnexpectedEOF // the Content-Length said there'd be more.
+		}
+	}
+	if exceeds(b.read, b.max) {
+		n -= int(b.read - b.max)
+		err = b.mr.bodyErrorf(ErrBodyTooLarge, "body is limited to %d bytes", b.max)
+		atomic.StoreInt32(&b.tooLarge, 1)
+	}
+	if err == nil && b.remaining == 0 {
+		err = io.EOF
+	}
+	return n, b.finish(err)
+}
+
+func (b *body) finish(err error) error {
+	if err == io.EOF && b.err == nil && b.onEOF != nil {
+		b.onEOF()
+	}
+	b.err = err
+	return err
+}
+
+// drain skips over up to max bytes of what's left of the body, closed or not,
+// reporting whether it got to the end cleanly; if not, the connection can't be reused.
+func (b *body) drain(max int64) bool {
+	_, err := io.CopyN(io.Discard, readerFunc(b.read1), max)
+	return err == io.EOF
+}
+
+// readerFunc lets an ordinary function be used as an io.Reader.
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
@@ -0,0 +1,272 @@
+package http
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Chunked transfer coding, as described in RFC 9112 section 7.1:
+//
+//	chunked-body   = *chunk
+//	                 last-chunk
+//	                 trailer-section
+//	                 CRLF
+//	chunk          = chunk-size [ chunk-ext ] CRLF
+//	                 chunk-data CRLF
+//	last-chunk     = 1*("0") [ chunk-ext ] CRLF
+//
+// e.g, "5\r\nhello\r\n0\r\n\r\n" is the body "hello".
+
+// maxChunkLineBytes limits the chunk-size line, extensions and all. Nobody needs 4 KiB of chunk extensions.
+const maxChunkLineBytes = 4 << 10
+
+// ChunkedReader decodes a body sent with "Transfer-Encoding: chunked".
+// Read returns io.EOF after the last chunk and the trailer section have been consumed;
+// the underlying reader is then positioned right after the body, ready for the next message.
+type ChunkedReader struct {
+	mr      *msgReader
+	n       int64   // bytes left in the current chunk
+	ext     string  // chunk extensions of the current chunk, without the leading ';'
+	trailer Headers // trailer fields, filled in once we hit the last chunk
+	err     error   // sticky error; io.EOF once we're done.
+	limits  Parser  // limits on the trailer section
+}
+
+// NewChunkedReader returns a ChunkedReader that reads the chunked body from br.
+// Malformed chunks get a *ParseError of kind ErrBadChunk, with offsets counted from the start of the body.
+func NewChunkedReader(br *bufio.Reader) *ChunkedReader { return &ChunkedReader{mr: &msgReader{br: br}} }
+
+// Extension returns the raw chunk extensions of the chunk currently being read, e.g `name="value";other`.
+// Most chunks don't have any, in which case it's empty.
+func (cr *ChunkedReader) Extension() string { return cr.ext }
+
+// Trailer returns the trailer fields sent after the last chunk. It's only valid after Read has returned io.EOF.
+func (cr *ChunkedReader) Trailer() Headers { return cr.trailer }
+
+func (cr *ChunkedReader) Read(p []byte) (n int, err error) {
+	for cr.err == nil && cr.n == 0 {
+		cr.err = cr.nextChunk()
+	}
+	if cr.err != nil {
+		return 0, cr.err
+	}
+	if int64(len(p)) > cr.n {
+		p = p[:cr.n]
+	}
+	n, err = cr.mr.Read(p)
+	cr.n -= int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF // the chunk said there'd be more.
+	}
+	if err == nil && cr.n == 0 {
+		err = cr.readCRLF() // every chunk's data is followed by a CRLF.
+	}
+	cr.err = err
+	return n, err
+}
+
+// nextChunk reads the next chunk-size line. On the last chunk, it also reads the trailer section and returns io.EOF.
+func (cr *ChunkedReader) nextChunk() error {
+	line, err := cr.mr.readLine(maxChunkLineBytes)
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	if err == errLineTooLong {
+		return cr.mr.errorf(ErrBadChunk, "", "chunk size line is limited to %d bytes", maxChunkLineBytes)
+	}
+	if err != nil {
+		return err
+	}
+	size, ext, _ := strings.Cut(line, ";")
+	size = strings.TrimRight(size, " \t") // BWS is allowed before the extensions.
+	if cr.n, err = parseChunkSize(size); err != nil {
+		return cr.mr.errorf(ErrBadChunk, line, "%v", err)
+	}
+	if cr.ext, err = parseChunkExt(ext); err != nil {
+		return cr.mr.errorf(ErrBadChunk, line, "%v", err)
+	}
+	if cr.n > 0 {
+		return nil
+	}
+	// last chunk: the trailer section is just more header fields, terminated by an empty line.
+	cr.trailer, err = cr.limits.readHeaders(cr.mr)
+	return ifNil(err, io.EOF)
+}
+
+func (cr *ChunkedReader) readCRLF() error {
+	line, err := cr.mr.readLine(len("\r\n"))
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	if err != nil && err != errLineTooLong {
+		return err
+	}
+	if err == errLineTooLong || line != "" {
+		return cr.mr.errorf(ErrBadChunk, line, "chunk data should be followed by CRLF")
+	}
+	return nil
+}
+
+func parseChunkSize(s string) (int64, error) {
+	if s == "" {
+		return 0, errors.New("missing chunk size")
+	}
+	for i := range s { // strconv would accept a leading '+' or "0x"; we want hex digits only.
+		if !isHexDigit(s[i]) {
+			return 0, fmt.Errorf("invalid chunk size %q", s)
+		}
+	}
+	n, err := strconv.ParseInt(s, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("chunk size %q too large", s)
+	}
+	return n, nil
+}
+
+// parseChunkExt validates the chunk extensions following the first ';' and returns them, trimmed.
+// We don't understand any extensions, and RFC 9112 says we MUST ignore the ones we don't understand,
+// but they still have to be well formed: ext-name [ "=" ( token / quoted-string ) ], separated by ';'.
+func parseChunkExt(s string) (string, error) {
+	s = strings.Trim(s, " \t")
+	if s == "" {
+		return "", nil
+	}
+	for _, ext := range splitChunkExts(s) {
+		name, val, hasVal := strings.Cut(strings.Trim(ext, " \t"), "=")
+		name, val = strings.Trim(name, " \t"), strings.Trim(val, " \t")
+		if !isToken(name) || hasVal && !isToken(val) && !isQuotedString(val) {
+			return "", fmt.Errorf("invalid chunk extension %q", ext)
+		}
+	}
+	return s, nil
+}
+
+// splitChunkExts splits chunk extensions on the ';' between them, but not on any inside a quoted-string,
+// e.g `a="x;y"; b` is `a="x;y"` and ` b`. A '\' in a quoted-string escapes the next character, quotes included.
+func splitChunkExts(s string) []string {
+	var exts []string
+	start, quoted := 0, false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quoted && c == '\\':
+			i++ // skip the escaped character.
+		case c == '"':
+			quoted = !quoted
+		case c == ';' && !quoted:
+			exts = append(exts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(exts, s[start:])
+}
+
+// ifNil returns err, or def if err is nil.
+func ifNil(err, def error) error {
+	if err == nil {
+		return def
+	}
+	return err
+}
+
+// ChunkedWriter encodes everything written to it as chunks of a "Transfer-Encoding: chunked" body.
+// Each call to Write produces exactly one chunk. Close must be called to write the last chunk and the trailer;
+// it does NOT close the underlying writer.
+type ChunkedWriter struct {
+	w       io.Writer
+	Trailer Headers // written after the last chunk by Close.
+}
+
+// NewChunkedWriter returns a ChunkedWriter that writes chunks to w.
+func NewChunkedWriter(w io.Writer) *ChunkedWriter { return &ChunkedWriter{w: w} }
+
+// Write writes p as a single chunk. Writing an empty slice is a no-op, since an empty chunk would end the body.
+func (cw *ChunkedWriter) Write(p []byte) (n int, err error) { return cw.WriteChunk(p, "") }
+
+// WriteChunk writes p as a single chunk with the given chunk extensions, e.g `name="value"`.
+func (cw *ChunkedWriter) WriteChunk(p []byte, ext string) (n int, err error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if _, err := fmt.Fprintf(cw.w, "%x%s\r\n", len(p), extSuffix(ext)); err != nil {
+		return 0, err
+	}
+	if n, err = cw.w.Write(p); err != nil {
+		return n, err
+	}
+	_, err = io.WriteString(cw.w, "\r\n")
+	return n, err
+}
+
+// Close writes the last (zero-length) chunk, followed by the trailer fields and the final CRLF.
+func (cw *ChunkedWriter) Close() error {
+	if _, err := io.WriteString(cw.w, "0\r\n"); err != nil {
+		return err
+	}
+	for _, h := range cw.Trailer {
+		if _, err := fmt.Fprintf(cw.w, "%s: %s\r\n", h.Key, h.Value); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(cw.w, "\r\n")
+	return err
+}
+
+func extSuffix(ext string) string {
+	if ext == "" {
+		return ""
+	}
+	return ";" + ext
+}
+
+// isChunked returns true if the last transfer coding in the Transfer-Encoding header(s) is "chunked".
+// RFC 9112 section 6.1 says chunked, if present, MUST be the final coding, so that's the only place we look.
+func isChunked(headers Headers) bool {
+	te := headers.Values("Transfer-Encoding")
+	if len(te) == 0 {
+		return false
+	}
+	codings := strings.Split(te[len(te)-1], ",")
+	return strings.EqualFold(strings.TrimSpace(codings[len(codings)-1]), "chunked")
+}
+
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// isToken returns true if s is a token as defined in RFC 9110 section 5.6.2:
+// one or more of the visible ASCII characters, except for delimiters.
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := range s {
+		c := s[i]
+		if c <= ' ' || c >= 0x7f || strings.IndexByte(`"(),/:;<=>?@[\]{}`, c) != -1 {
+			return false
+		}
+	}
+	return true
+}
+
+// isQuotedString returns true if s is a quoted-string as defined in RFC 9110 section 5.6.4, e.g `"a \"quoted\" string"`.
+func isQuotedString(s string) bool {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return false
+	}
+	for i := 1; i < len(s)-1; i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			i++ // skip the escaped character.
+			if i == len(s)-1 {
+				return false // the closing quote was escaped.
+			}
+		case c == '"', c < ' ' && c != '\t', c == 0x7f:
+			return false
+		}
+	}
+	return true
+}
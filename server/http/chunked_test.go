@@ -0,0 +1,108 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChunkedReader(t *testing.T) {
+	for name, tt := range map[string]struct {
+		input       string
+		wantBody    string
+		wantTrailer Headers
+	}{
+		"single chunk": {
+			input:    "5\r\nhello\r\n0\r\n\r\n",
+			wantBody: "hello",
+		},
+		"many chunks": {
+			input:    "5\r\nhello\r\n1\r\n \r\nA\r\n0123456789\r\n0\r\n\r\n",
+			wantBody: "hello 0123456789",
+		},
+		"extensions": {
+			input:    "5;name=value\r\nhello\r\n6 ; quoted=\"a \\\"b\\\"\" ; bare\r\n world\r\n0;last\r\n\r\n",
+			wantBody: "hello world",
+		},
+		"semicolon in quoted extension": {
+			input:    "5;a=\"x;y\"\r\nhello\r\n6;b=\"\\\";\";c\r\n world\r\n0\r\n\r\n",
+			wantBody: "hello world",
+		},
+		"trailer": {
+			input:       "5\r\nhello\r\n0\r\nexpires: never\r\n\r\n",
+			wantBody:    "hello",
+			wantTrailer: Headers{{"Expires", "never"}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			br := bufio.NewReader(strings.NewReader(tt.input + "NEXT"))
+			cr := NewChunkedReader(br)
+			got, err := io.ReadAll(cr)
+			if err != nil {
+				t.Fatalf("ReadAll(%q) returned error: %v", tt.input, err)
+			}
+			if string(got) != tt.wantBody {
+				t.Errorf("ReadAll(%q) = %q, want %q", tt.input, got, tt.wantBody)
+			}
+			if !reflect.DeepEqual(cr.Trailer(), tt.wantTrailer) {
+				t.Errorf("Trailer() = %v, want %v", cr.Trailer(), tt.wantTrailer)
+			}
+			// the reader should stop right after the body.
+			if rest, _ := io.ReadAll(br); string(rest) != "NEXT" {
+				t.Errorf("after body, reader has %q, want %q", rest, "NEXT")
+			}
+		})
+	}
+}
+
+func TestChunkedReaderErrors(t *testing.T) {
+	for name, input := range map[string]string{
+		"truncated data":     "5\r\nhel",
+		"missing last":       "5\r\nhello\r\n",
+		"bad size":           "zz\r\nhello\r\n0\r\n\r\n",
+		"signed size":        "+5\r\nhello\r\n0\r\n\r\n",
+		"no crlf":            "5\r\nhelloX\r\n0\r\n\r\n",
+		"bad extension":      "5;a b\r\nhello\r\n0\r\n\r\n",
+		"unterminated quote": "5;a=\"x;y\r\nhello\r\n0\r\n\r\n",
+		"overflowed size":    "fffffffffffffffff\r\nhello\r\n0\r\n\r\n",
+	} {
+		if _, err := io.ReadAll(NewChunkedReader(bufio.NewReader(strings.NewReader(input)))); err == nil {
+			t.Errorf("%s: ReadAll(%q) returned no error", name, input)
+		}
+	}
+}
+
+func TestChunkedWriter(t *testing.T) {
+	b := new(bytes.Buffer)
+	cw := NewChunkedWriter(b)
+	cw.Trailer = []Header{{"Expires", "never"}}
+	io.WriteString(cw, "hello")
+	io.WriteString(cw, "") // should NOT end the body early.
+	cw.WriteChunk([]byte(" world"), "name=value")
+	cw.Close()
+
+	const want = "5\r\nhello\r\n6;name=value\r\n world\r\n0\r\nExpires: never\r\n\r\n"
+	if b.String() != want {
+		t.Errorf("ChunkedWriter wrote %q, want %q", b.String(), want)
+	}
+}
+
+func TestChunkedRoundTrip(t *testing.T) {
+	resp := &Response{
+		StatusCode: 200,
+		Proto:      "HTTP/1.1",
+		Headers:    []Header{{"Transfer-Encoding", "chunked"}},
+		Trailer:    []Header{{"Expires", "never"}},
+	}
+	resp.SetBody([]byte("Hello World"))
+	got, err := ParseResponse(resp.String())
+	if err != nil {
+		t.Fatalf("ParseResponse(%q) returned error: %v", resp.String(), err)
+	}
+	if !reflect.DeepEqual(got, resp) {
+		t.Errorf("ParseResponse(%q) = %#+v, want %#+v", resp.String(), got, resp)
+	}
+}
@@ -0,0 +1,394 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Client sends requests over raw TCP (or TLS, for "https"), writing them with Request.WriteTo and reading the
+// responses with a Parser. Connections are kept open and reused for later requests to the same host; see pool.go.
+// The zero value is ready to use; every timeout is optional, and zero means none.
+// A Client is safe for concurrent use, and should be reused rather than created as needed, so its pool is too.
+type Client struct {
+	DialTimeout           time.Duration // how long to wait for the TCP connection to be established
+	TLSHandshakeTimeout   time.Duration // how long to wait for the TLS handshake, for "https" requests
+	ResponseHeaderTimeout time.Duration // how long to wait for the response headers, once the request is written
+	ExpectContinueTimeout time.Duration // how long to wait for a 100 Continue to a request with "Expect: 100-continue" before sending the body anyway; zero sends it straight away. See continue.go
+	TLSConfig             *tls.Config   // TLS configuration for "https" requests; if nil, the defaults are used
+	Parser                Parser        // limits on the responses we'll read; the zero value uses the defaults
+
+	MaxIdleConnsPerHost int           // most idle connections to keep per host; 0 means DefaultMaxIdleConnsPerHost, negative means none.
+	MaxConnsPerHost     int           // most connections per host, idle or in use; requests past that wait their turn. 0 means no limit.
+	IdleConnTimeout     time.Duration // how long a connection can sit idle in the pool before it's closed
+
+	// CheckRedirect, if set, is called before following a redirect, with the request about to be sent and the ones
+	// sent so far, oldest first. If it returns an error, Do stops and returns it, unless it's ErrUseLastResponse,
+	// in which case Do returns the redirect response itself. If nil, Do follows up to DefaultMaxRedirects redirects.
+	CheckRedirect func(req *Request, via []*Request) error
+
+	// DisableCompression stops the Client from asking for gzip or deflate compressed responses. Otherwise, if a request
+	// doesn't have an Accept-Encoding header (or a Range header), the Client adds "Accept-Encoding: gzip, deflate",
+	// and decompresses the response transparently. See Response.Uncompressed.
+	DisableCompression bool
+
+	// Jar, if set, stores the cookies from every response, redirects included, and adds the ones that apply
+	// to every request. Any Cookie header already on the request is sent too.
+	Jar CookieJar
+
+	mu        sync.Mutex
+	idle      map[connKey][]*persistConn // idle connections, most recently used last
+	numConns  map[connKey]int            // open connections, idle or in use
+	connFreed map[connKey]chan struct{}  // closed when a connection comes back to the pool or is closed
+}
+
+// Do sends req and returns the response, following redirects as described in redirect.go.
+// The request is sent to req.Scheme and req.Authority if it has them, i.e. if it was built from an absolute URL;
+// otherwise, it's sent over plain HTTP to the Host header.
+//
+// The response body streams from the connection: the caller MUST close it when done. Reading it to the end
+// before closing it lets the connection be reused.
+// ctx covers the whole exchange, body included: if it's canceled, Do, and any read of the body, fail with ctx.Err().
+// Like net/http, Do only returns an error if it couldn't get a response: a 404 or a 500 is still a response.
+func (c *Client) Do(ctx context.Context, req *Request) (*Response, error) {
+	var via []*Request
+	for {
+		resp, err := c.do(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		next, err := redirectRequest(req, resp)
+		if err != nil {
+			closeBody(resp)
+			return nil, err
+		}
+		if next == nil {
+			return resp, nil // not a redirect, or not one we can follow.
+		}
+		via = append(via, req)
+		if err := c.checkRedirect(next, via); err != nil {
+			if err == ErrUseLastResponse {
+				return resp, nil
+			}
+			closeBody(resp)
+			return nil, fmt.Errorf("http: redirect to %s: %w", next.url(), err)
+		}
+		discardBody(resp)
+		req = next
+	}
+}
+
+// do sends req and returns the response, without following redirects.
+func (c *Client) do(ctx context.Context, req *Request) (*Response, error) {
+	out, key, err := prepareRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if c.Jar != nil {
+		for _, cookie := range c.Jar.Cookies(req.url()) {
+			out.AddCookie(cookie)
+		}
+	}
+	// if the caller asked for an encoding, they get it as is; the same goes for ranges, which are of the encoded body.
+	askedForCompression := !c.DisableCompression && !out.Headers.Has("Accept-Encoding") && !out.Headers.Has("Range")
+	if askedForCompression {
+		out.Headers.Set("Accept-Encoding", "gzip, deflate")
+	}
+	for {
+		pc, err := c.getConn(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.send(ctx, pc, out)
+		if err != nil && pc.reused && shouldRetry(out, err) {
+			continue // the server closed the idle connection just as we picked it up; try another.
+		}
+		if err != nil {
+			return nil, err
+		}
+		if c.Jar != nil {
+			if cookies := resp.Cookies(); len(cookies) > 0 {
+				c.Jar.SetCookies(req.url(), cookies)
+			}
+		}
+		if askedForCompression {
+			decompressBody(resp)
+		}
+		resp.Request = req
+		return resp, nil
+	}
+}
+
+// send sends req over pc and reads the response headers. pc goes back to the pool (or is closed) once the response body
+// is done with; if there's an error, it's closed straight away.
+func (c *Client) send(ctx context.Context, pc *persistConn, req *Request) (*Response, error) {
+	stop := watchContext(ctx, pc.conn)
+	resp, wroteBody, err := c.roundTrip(ctx, pc, req)
+	if err != nil {
+		stop()
+		c.closeConn(pc)
+		return nil, contextError(ctx, err)
+	}
+	// if the server turned down the body, it may still be expecting it, so the connection is in an unknown state.
+	reusable := wroteBody && req.keepAlive() && resp.keepAlive()
+	release := func(done bool) {
+		stop()
+		if done && reusable && ctx.Err() == nil {
+			c.putIdle(pc)
+		} else {
+			c.closeConn(pc)
+		}
+	}
+	if resp.Body == nil {
+		release(true)
+		return resp, nil
+	}
+	resp.Body = &clientBody{rc: resp.Body, ctx: ctx, release: release}
+	return resp, nil
+}
+
+// shouldRetry reports whether req can be sent again on a new connection after failing with err on a reused one.
+// That's only the case if the server hung up without answering, which it's allowed to do to an idle connection,
+// and we can send the body again. But the server may have hung up after acting on the request, so unless none of
+// it was sent, only idempotent requests are retried, which do no harm done twice (RFC 9110 section 9.2.2).
+func shouldRetry(req *Request, err error) bool {
+	switch req.Body.(type) {
+	case nil, *memBody:
+	default:
+		return false // it's been used up.
+	}
+	if !errors.Is(err, io.EOF) && !errors.Is(err, syscall.ECONNRESET) && !errors.Is(err, syscall.EPIPE) {
+		return false
+	}
+	var nothingWritten nothingWrittenError
+	return errors.As(err, &nothingWritten) || idempotent(req.Method)
+}
+
+// idempotent reports whether sending a request with the given method more than once has the same effect as once.
+func idempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	default:
+		return false
+	}
+}
+
+// nothingWrittenError is an error sending a request of which not a byte made it onto the connection.
+type nothingWrittenError struct{ err error }
+
+func (e nothingWrittenError) Error() string { return e.err.Error() }
+func (e nothingWrittenError) Unwrap() error { return e.err }
+
+// prepareRequest figures out where to send req, and makes a copy of it to send there, with an origin-form target
+// and a Host header. The original isn't modified.
+func prepareRequest(req *Request) (out *Request, key connKey, err error) {
+	key, authority, err := requestAddr(req)
+	if err != nil {
+		return nil, key, err
+	}
+
+	r2 := *req // shallow copy; the body is shared
+	out = &r2
+	out.Scheme, out.Authority = "", "" // origin-form: "GET /path HTTP/1.1"; the authority goes in the Host header.
+	out.Headers = req.Headers.Clone()
+	if !out.Headers.Has("Host") {
+		out.Headers.Set("Host", authority)
+	}
+	if out.Path == "" {
+		out.Path = "/"
+	}
+	if !out.Headers.Has("Content-Length") && !out.Headers.Has("Transfer-Encoding") {
+		switch n := bodyLength(out.Body, out.ContentLength); {
+		case n > 0:
+			out.Headers.Set("Content-Length", fmt.Sprint(n))
+		case n < 0:
+			out.Headers.Set("Transfer-Encoding", "chunked")
+		}
+	}
+	return out, key, nil
+}
+
+// url returns the URL the Client sends r to.
+func (r *Request) url() *url.URL {
+	key, authority, _ := requestAddr(r)
+	return &url.URL{Scheme: key.scheme, Host: authority, Path: r.Path, RawQuery: r.RawQuery}
+}
+
+// requestAddr returns where req should be sent, along with its authority, e.g "example.com" for
+// "http://example.com/", whose key has the address "example.com:80".
+func requestAddr(req *Request) (key connKey, authority string, err error) {
+	scheme, authority := strings.ToLower(req.Scheme), req.Authority
+	if scheme == "" {
+		scheme = "http"
+	}
+	if authority == "" {
+		authority = req.Headers.Get("Host")
+	}
+	if authority == "" {
+		return key, "", errors.New("http: request has no Host header or Authority")
+	}
+	var defaultPort string
+	switch scheme {
+	case "http":
+		defaultPort = "80"
+	case "https":
+		defaultPort = "443"
+	default:
+		return key, "", fmt.Errorf("http: unsupported scheme %q", req.Scheme)
+	}
+	addr := authority
+	if _, _, err := net.SplitHostPort(authority); err != nil {
+		addr = net.JoinHostPort(strings.Trim(authority, "[]"), defaultPort) // no port; [] is for IPv6 literals
+	}
+	return connKey{scheme: scheme, addr: strings.ToLower(addr)}, authority, nil
+}
+
+// dial connects to addr, doing the TLS handshake if asked.
+func (c *Client) dial(ctx context.Context, addr string, useTLS bool) (net.Conn, error) {
+	d := net.Dialer{Timeout: c.DialTimeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("http: dial %s: %w", addr, err)
+	}
+	if !useTLS {
+		return conn, nil
+	}
+	cfg := new(tls.Config)
+	if c.TLSConfig != nil {
+		cfg = c.TLSConfig.Clone()
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+	hctx := ctx
+	if c.TLSHandshakeTimeout > 0 {
+		var cancel context.CancelFunc
+		hctx, cancel = context.WithTimeout(ctx, c.TLSHandshakeTimeout)
+		defer cancel()
+	}
+	tlsConn := tls.Client(conn, cfg)
+	if err := tlsConn.HandshakeContext(hctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("http: TLS handshake with %s: %w", addr, contextError(ctx, err))
+	}
+	return tlsConn, nil
+}
+
+// roundTrip writes req to pc and reads the response headers. It reports whether it wrote the body, which it
+// doesn't if the request expects a 100 Continue and the server answers with a final response instead.
+func (c *Client) roundTrip(ctx context.Context, pc *persistConn, req *Request) (resp *Response, wroteBody bool, err error) {
+	start := pc.written
+	writeError := func(err error) error {
+		err = fmt.Errorf("http: writing request: %w", err)
+		if pc.written == start {
+			return nothingWrittenError{err}
+		}
+		return err
+	}
+	if _, err := req.writeHeader(pc.bw); err != nil {
+		return nil, false, writeError(err)
+	}
+	if req.Body != nil && c.ExpectContinueTimeout > 0 && req.expectsContinue() {
+		if err := pc.bw.Flush(); err != nil {
+			return nil, false, writeError(err)
+		}
+		if resp, err := c.awaitContinue(ctx, pc, req); resp != nil || err != nil {
+			req.Body.Close() // as writing it would have.
+			return resp, false, err
+		}
+	}
+	if _, err := writeBody(pc.bw, req.Body, req.Headers, &req.Trailer); err != nil {
+		return nil, false, writeError(err)
+	}
+	if err := pc.bw.Flush(); err != nil {
+		return nil, false, writeError(err)
+	}
+	// setting a read deadline replaces the one watchContext sets when ctx is canceled, so after each, check
+	// whether it has been: if it was canceled before, the watcher won't set it again.
+	if c.ResponseHeaderTimeout > 0 {
+		pc.conn.SetReadDeadline(time.Now().Add(c.ResponseHeaderTimeout))
+		if err := ctx.Err(); err != nil {
+			return nil, false, err
+		}
+	}
+	resp, err = c.Parser.readResponse(pc.br, req)
+	if err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() && c.ResponseHeaderTimeout > 0 {
+			return nil, false, fmt.Errorf("http: timeout awaiting response headers: %w", err)
+		}
+		return nil, false, fmt.Errorf("http: reading response: %w", err)
+	}
+	if c.ResponseHeaderTimeout > 0 {
+		pc.conn.SetReadDeadline(time.Time{}) // the header timeout doesn't cover the body.
+		if err := ctx.Err(); err != nil {
+			closeBody(resp)
+			return nil, false, err
+		}
+	}
+	return resp, true, nil
+}
+
+// watchContext interrupts any I/O on conn once ctx is done, until stop is called.
+// Once stop returns, watchContext won't touch conn again, so it can be reused if ctx isn't done.
+func watchContext(ctx context.Context, conn net.Conn) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {} // can't be canceled; nothing to watch.
+	}
+	done, exited := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Unix(1, 0)) // a deadline in the past fails any pending and future I/O.
+		case <-done:
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-exited
+	}
+}
+
+// contextError reports ctx's error in place of err if ctx is done, since that's the real reason things went wrong.
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// clientBody is a response body that releases its connection once it's been read to the end or closed.
+// release is told whether the body was read to the end; if not, the connection can't be reused.
+type clientBody struct {
+	rc      io.ReadCloser
+	ctx     context.Context
+	release func(done bool)
+	once    sync.Once
+}
+
+func (cb *clientBody) Read(p []byte) (int, error) {
+	n, err := cb.rc.Read(p)
+	if err != nil && err != io.EOF {
+		err = contextError(cb.ctx, err)
+	}
+	if err != nil {
+		cb.once.Do(func() { cb.release(err == io.EOF) })
+	}
+	return n, err
+}
+
+func (cb *clientBody) Close() error {
+	err := cb.rc.Close()
+	cb.once.Do(func() { cb.release(false) })
+	return err
+}
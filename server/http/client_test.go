@@ -0,0 +1,417 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientDo(t *testing.T) {
+	addr := startServer(t, &Server{Handler: HandlerFunc(func(r *Request) *Response {
+		if r.Path == "/stream" {
+			return &Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("streamed"))}
+		}
+		body, err := r.BodyBytes()
+		if err != nil {
+			return nil
+		}
+		resp, _ := NewResponse(200, fmt.Sprintf("%s %s %s: %s", r.Method, r.Path, r.Headers.Get("Host"), body))
+		return resp
+	})})
+
+	for name, tt := range map[string]struct {
+		req      func() *Request
+		wantBody string
+	}{
+		"GET": {func() *Request {
+			req, _ := NewRequest("GET", "/hello", addr, "")
+			return req
+		}, "GET /hello " + addr + ": "},
+		"POST": {func() *Request {
+			req, _ := NewRequest("POST", "/echo", addr, "hi")
+			return req
+		}, "POST /echo " + addr + ": hi"},
+		"streamed request body": {func() *Request {
+			req, _ := NewRequest("POST", "/echo", addr, "")
+			req.Body = io.NopCloser(strings.NewReader("chunked"))
+			return req
+		}, "POST /echo " + addr + ": chunked"},
+		"streamed response body": {func() *Request {
+			req, _ := NewRequest("GET", "/stream", addr, "")
+			return req
+		}, "streamed"},
+		"absolute URL": {func() *Request {
+			return &Request{Method: "GET", Path: "/abs", Scheme: "http", Authority: addr}
+		}, "GET /abs " + addr + ": "},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := tt.req()
+			resp, err := new(Client).Do(context.Background(), req)
+			if err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+			if resp.Request != req {
+				t.Errorf("resp.Request is %p, want %p", resp.Request, req)
+			}
+			if body := bodyOf(t, resp); resp.StatusCode != 200 || body != tt.wantBody {
+				t.Errorf("got %d %q, want 200 %q", resp.StatusCode, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	silent := stallingServer(t, "")
+	// closed is an address nobody's listening on.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	closed := l.Addr().String()
+	l.Close()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for name, tt := range map[string]struct {
+		client  *Client // if nil, a new Client
+		ctx     func() (context.Context, context.CancelFunc)
+		req     *Request
+		wantErr error // checked with errors.Is; nil means any error will do
+	}{
+		"no host":         {req: &Request{Method: "GET", Path: "/"}},
+		"bad scheme":      {req: &Request{Method: "GET", Path: "/", Scheme: "ftp", Authority: "localhost"}},
+		"connect refused": {req: &Request{Method: "GET", Path: "/", Authority: closed}},
+		"canceled": {
+			req:     &Request{Method: "GET", Path: "/", Authority: silent},
+			ctx:     func() (context.Context, context.CancelFunc) { return canceled, func() {} },
+			wantErr: context.Canceled,
+		},
+		"deadline": {
+			req: &Request{Method: "GET", Path: "/", Authority: silent},
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 50*time.Millisecond)
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+		"deadline, with a response header timeout": {
+			client: &Client{ResponseHeaderTimeout: time.Minute},
+			req:    &Request{Method: "GET", Path: "/", Authority: silent},
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 50*time.Millisecond)
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+		"canceled, with a response header timeout": {
+			client:  &Client{ResponseHeaderTimeout: time.Minute},
+			req:     &Request{Method: "GET", Path: "/", Authority: silent},
+			ctx:     func() (context.Context, context.CancelFunc) { return canceled, func() {} },
+			wantErr: context.Canceled,
+		},
+		"response header timeout": {
+			client:  &Client{ResponseHeaderTimeout: 50 * time.Millisecond},
+			req:     &Request{Method: "GET", Path: "/", Authority: silent},
+			wantErr: errTimeout,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.Background(), func() {}
+			if tt.ctx != nil {
+				ctx, cancel = tt.ctx()
+			}
+			defer cancel()
+			client := tt.client
+			if client == nil {
+				client = new(Client)
+			}
+			resp, err := client.Do(ctx, tt.req)
+			if err == nil {
+				resp.Body.Close()
+				t.Fatalf("Do: got a %d response, want an error", resp.StatusCode)
+			}
+			if tt.wantErr == errTimeout {
+				var ne net.Error
+				if !errors.As(err, &ne) || !ne.Timeout() {
+					t.Errorf("Do: got %v, want a timeout", err)
+				}
+			} else if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Do: got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// errTimeout stands for any net.Error that's a timeout, in TestClientErrors.
+var errTimeout = errors.New("timeout")
+
+func TestClientCancelDuringBody(t *testing.T) {
+	addr := stallingServer(t, "HTTP/1.1 200 OK\r\nContent-Length: 100\r\n\r\nfirst")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := NewRequest("GET", "/", addr, "")
+	resp, err := new(Client).Do(ctx, req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	defer resp.Body.Close()
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(resp.Body, buf); err != nil || string(buf) != "first" {
+		t.Fatalf("first read: got %q, %v", buf, err)
+	}
+	cancel()
+	if _, err := io.ReadAll(resp.Body); !errors.Is(err, context.Canceled) {
+		t.Errorf("read after cancel: got %v, want %v", err, context.Canceled)
+	}
+}
+
+// stallingServer accepts connections on a random local port and answers each of them with reply,
+// which may be empty or cut short, and then nothing more, until the client hangs up. It returns the address to dial.
+func stallingServer(t *testing.T, reply string) string {
+	t.Helper()
+	l := listen(t)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.Write([]byte(reply))
+				io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestClientPool(t *testing.T) {
+	handler := HandlerFunc(func(r *Request) *Response {
+		resp, _ := NewResponse(200, "ok")
+		if r.Path == "/close" {
+			resp.Headers.Set("Connection", "close")
+		}
+		return resp
+	})
+	for name, tt := range map[string]struct {
+		client     *Client
+		path       string
+		concurrent bool
+		wantConns  int
+	}{
+		"reused":             {client: new(Client), path: "/", wantConns: 1},
+		"server closes":      {client: new(Client), path: "/close", wantConns: 3},
+		"no idle conns":      {client: &Client{MaxIdleConnsPerHost: -1}, path: "/", wantConns: 3},
+		"idle timeout":       {client: &Client{IdleConnTimeout: time.Nanosecond}, path: "/", wantConns: 3},
+		"max conns per host": {client: &Client{MaxConnsPerHost: 1}, path: "/", concurrent: true, wantConns: 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			l := &countingListener{Listener: listen(t)}
+			go (&Server{Handler: handler}).Serve(l)
+
+			get := func() error {
+				req, _ := NewRequest("GET", tt.path, l.Addr().String(), "")
+				resp, err := tt.client.Do(context.Background(), req)
+				if err != nil {
+					return err
+				}
+				if _, err := resp.BodyBytes(); err != nil {
+					return err
+				}
+				return resp.Body.Close()
+			}
+			errs := make(chan error, 3)
+			for i := 0; i < 3; i++ {
+				if tt.concurrent {
+					go func() { errs <- get() }()
+				} else {
+					errs <- get()
+				}
+			}
+			for i := 0; i < 3; i++ {
+				if err := <-errs; err != nil {
+					t.Fatalf("request failed: %v", err)
+				}
+			}
+			if got := l.accepted(); got != tt.wantConns {
+				t.Errorf("server accepted %d connections, want %d", got, tt.wantConns)
+			}
+		})
+	}
+}
+
+func TestClientStaleConn(t *testing.T) {
+	// the server answers one request per connection, but doesn't say so: the client only finds out when it's hung up.
+	l := &countingListener{Listener: listen(t)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if _, err := ReadRequest(bufio.NewReader(conn)); err == nil {
+					conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok"))
+				}
+			}()
+		}
+	}()
+	client := new(Client)
+	for i := 0; i < 3; i++ {
+		req, _ := NewRequest("GET", "/", l.Addr().String(), "")
+		resp, err := client.Do(context.Background(), req)
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		if body := bodyOf(t, resp); body != "ok" {
+			t.Errorf("request %d: got body %q, want %q", i, body, "ok")
+		}
+		time.Sleep(10 * time.Millisecond) // let the server hang up.
+	}
+	if got := l.accepted(); got != 3 {
+		t.Errorf("server accepted %d connections, want 3", got)
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	// the server answers the first request on each connection, but hangs up on the second, once it's read it, as it
+	// might if it crashed while acting on it: the client can't tell that from a server closing an idle connection.
+	l := listen(t)
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				for i := 0; i < 2; i++ {
+					req, err := ReadRequest(br)
+					if err != nil {
+						return
+					}
+					req.BodyBytes()
+					mu.Lock()
+					seen[req.Method]++
+					mu.Unlock()
+					if i == 0 {
+						conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok"))
+					}
+				}
+			}()
+		}
+	}()
+	for name, tt := range map[string]struct {
+		method    string
+		wantErr   bool
+		wantTimes int // how often the server saw the request
+	}{
+		"POST": {"POST", true, 1},
+		"PUT":  {"PUT", false, 2},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client := new(Client)
+			defer client.CloseIdleConnections()
+			req, _ := NewRequest("GET", "/", l.Addr().String(), "")
+			resp, err := client.Do(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bodyOf(t, resp)
+			req, _ = NewRequest(tt.method, "/", l.Addr().String(), "data")
+			resp, err = client.Do(context.Background(), req) // on the same connection, which the server hangs up.
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want one: %v", err, tt.wantErr)
+			}
+			if err == nil {
+				bodyOf(t, resp)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if seen[tt.method] != tt.wantTimes {
+				t.Errorf("the server saw %d %ss, want %d", seen[tt.method], tt.method, tt.wantTimes)
+			}
+		})
+	}
+}
+
+func TestClientClosesIdleConns(t *testing.T) {
+	handler := HandlerFunc(func(r *Request) *Response {
+		resp, _ := NewResponse(200, "ok")
+		return resp
+	})
+	for name, tt := range map[string]struct {
+		client *Client
+		server *Server
+	}{
+		"idle timeout":  {&Client{IdleConnTimeout: 20 * time.Millisecond}, &Server{Handler: handler}},
+		"server closes": {new(Client), &Server{Handler: handler, IdleTimeout: 20 * time.Millisecond}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			addr := startServer(t, tt.server)
+			req, _ := NewRequest("GET", "/", addr, "")
+			resp, err := tt.client.Do(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bodyOf(t, resp)
+			// no other request comes along to notice; the connection should be closed all the same.
+			for start := time.Now(); ; time.Sleep(5 * time.Millisecond) {
+				tt.client.mu.Lock()
+				idle, open := len(tt.client.idle[connKey{"http", addr}]), len(tt.client.numConns)
+				tt.client.mu.Unlock()
+				if idle == 0 && open == 0 {
+					break
+				}
+				if time.Since(start) > 2*time.Second {
+					t.Fatalf("%d connections still open, %d idle", open, idle)
+				}
+			}
+		})
+	}
+}
+
+// listen listens on a random local port, closing the listener when the test is done.
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+// countingListener counts the connections it accepts.
+type countingListener struct {
+	net.Listener
+	mu sync.Mutex
+	n  int
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.mu.Lock()
+		l.n++
+		l.mu.Unlock()
+	}
+	return conn, err
+}
+
+func (l *countingListener) accepted() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.n
+}
@@ -0,0 +1,242 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Content codings (RFC 9110 section 8.4.1) compress a body on the wire, while the Content-Type still says what it is
+// once decompressed. We speak the two everyone does: "gzip", and "deflate", which, despite the name, is the zlib
+// format (RFC 1950), not raw deflate.
+
+// DefaultCompressTypes are the content types Compress compresses if it isn't given any: text, and the formats
+// that are text in all but name. Images, video and archives are compressed already.
+var DefaultCompressTypes = []string{"text/*", "application/json", "application/javascript", "application/xml", "image/svg+xml"}
+
+// Compress returns middleware that compresses responses with gzip or deflate, whichever the client prefers
+// according to the q-values of its Accept-Encoding header, gzip winning ties. A response is left alone if
+//   - the client doesn't accept either coding
+//   - its Content-Type isn't one of contentTypes (or DefaultCompressTypes if there are none); a type ending in "/*"
+//     matches every subtype, e.g "text/*" matches "text/html; charset=utf-8"
+//   - its body is known to be shorter than minSize bytes, which isn't worth the bother; around 1KiB is reasonable
+//   - it's already encoded, or can't have a body anyway, e.g a 204
+//   - it's partial, i.e a 206 or anything else with a Content-Range, whose byte offsets are those of the unencoded body
+//
+// Any response it could compress gets "Vary: Accept-Encoding", so caches know to keep the versions apart.
+// In-memory bodies are compressed up front; other bodies stream through the compressor, chunked.
+//
+// A response to HEAD gets the same headers as one to GET would: its body, if it has one, is compressed too, for
+// the Content-Length, and then left out as usual. If the handler left it out already, e.g ServeContent, its
+// Content-Length stands in for the body's when it comes to minSize, and is dropped if it's compressed, with
+// ContentLength set to -1 to say the length is unknown.
+func Compress(minSize int, contentTypes ...string) Middleware {
+	if len(contentTypes) == 0 {
+		contentTypes = DefaultCompressTypes
+	}
+	return func(next Handler) Handler {
+		return HandlerFunc(func(r *Request) *Response {
+			resp := next.ServeHTTP(r)
+			bodiless := r.Method == "HEAD" && resp != nil && resp.Body == nil // what would've been the body was left out.
+			if resp == nil || !compressible(resp, bodiless, contentTypes) {
+				return resp
+			}
+			if !hasToken(resp.Headers, "Vary", "Accept-Encoding") {
+				resp.Headers.Add("Vary", "Accept-Encoding")
+			}
+			n := bodyLength(resp.Body, resp.ContentLength)
+			if bodiless {
+				n = -1
+				if resp.Headers.Has("Content-Length") {
+					if cl, err := contentLength(resp.Headers); err == nil {
+						n = cl
+					}
+				}
+			}
+			if n >= 0 && n < int64(minSize) {
+				return resp
+			}
+			enc := negotiateEncoding(r.Headers.Values("Accept-Encoding"), "gzip", "deflate")
+			if enc == "" {
+				return resp
+			}
+			if bodiless {
+				resp.Headers.Set("Content-Encoding", enc)
+				resp.Headers.Del("Content-Length") // we'd have to compress the body to know it.
+				resp.ContentLength = -1            // so the Server frames it as it would the GET, e.g chunked.
+				return resp
+			}
+			if err := compressBody(resp, enc); err != nil {
+				return nil // a 500; there's no good way to recover from a broken compressor.
+			}
+			return resp
+		})
+	}
+}
+
+// compressible reports whether resp is one Compress might compress, depending on the client. If bodiless, it's a
+// response to HEAD whose body was left out, but it's to be treated as if it were there.
+func compressible(resp *Response, bodiless bool, contentTypes []string) bool {
+	switch {
+	case (resp.Body == nil && !bodiless) || !statusHasBody(resp.StatusCode):
+		return false
+	case resp.Headers.Has("Content-Encoding"):
+		return false
+	case resp.StatusCode == 206 || resp.Headers.Has("Content-Range"):
+		return false
+	}
+	mt := mediaType(resp.Headers)
+	if mt == "" {
+		return false
+	}
+	for _, t := range contentTypes {
+		if t = strings.ToLower(t); t == mt || strings.HasSuffix(t, "/*") && strings.HasPrefix(mt, t[:len(t)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressBody replaces resp's body by its compressed form, fixing up the headers to match.
+func compressBody(resp *Response, enc string) error {
+	resp.Headers.Set("Content-Encoding", enc)
+	resp.Headers.Del("Content-Length")
+	if mb, ok := resp.Body.(*memBody); ok {
+		var buf bytes.Buffer
+		zw := newEncoder(&buf, enc)
+		if _, err := zw.Write(mb.b); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		resp.SetBody(buf.Bytes())
+		resp.Headers.Set("Content-Length", strconv.Itoa(buf.Len()))
+		return nil
+	}
+	// stream it: the compressor writes into a pipe as the server reads from the other end.
+	// Closing the pipe (the server always closes the body) stops the compressor, even if it's not done.
+	body := resp.Body
+	pr, pw := io.Pipe()
+	go func() {
+		defer body.Close()
+		zw := newEncoder(pw, enc)
+		_, err := io.Copy(zw, body)
+		if err == nil {
+			err = zw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	resp.Body, resp.ContentLength = pr, -1
+	return nil
+}
+
+// newEncoder returns a compressor for the content coding enc, which must be "gzip" or "deflate".
+func newEncoder(w io.Writer, enc string) io.WriteCloser {
+	if enc == "gzip" {
+		return gzip.NewWriter(w)
+	}
+	return zlib.NewWriter(w)
+}
+
+// negotiateEncoding picks the content coding to use for a client that sent the given Accept-Encoding headers:
+// the one of supported with the highest q-value, or the first of them on a tie. It returns "" if the client
+// accepts none of them. e.g, for "deflate;q=0.5, gzip;q=0.8", it's "gzip"; for "*;q=0", it's "".
+func negotiateEncoding(accept []string, supported ...string) string {
+	weights := make(map[string]float64)
+	for _, line := range accept {
+		for _, part := range strings.Split(line, ",") {
+			name, params, _ := strings.Cut(part, ";")
+			if name = strings.ToLower(strings.TrimSpace(name)); name == "" {
+				continue
+			}
+			if name == "x-gzip" {
+				name = "gzip" // RFC 9110 section 8.4.1.3: an alias.
+			}
+			weights[name] = qValue(params)
+		}
+	}
+	best, bestQ := "", 0.0
+	for _, enc := range supported {
+		q, ok := weights[enc]
+		if !ok {
+			q = weights["*"]
+		}
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// qValue returns the weight in a list of parameters like ";q=0.5", 1 if there's none, or 0 if it's malformed.
+func qValue(params string) float64 {
+	for _, p := range strings.Split(params, ";") {
+		key, val, ok := strings.Cut(strings.TrimSpace(p), "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(key), "q") {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		if err != nil || q < 0 || q > 1 {
+			return 0
+		}
+		return q
+	}
+	return 1
+}
+
+// decompressBody replaces resp's body with a reader that decompresses it as it's read, if it's gzip or deflate.
+func decompressBody(resp *Response) {
+	enc := strings.ToLower(strings.TrimSpace(resp.Headers.Get("Content-Encoding")))
+	if resp.Body == nil || (enc != "gzip" && enc != "x-gzip" && enc != "deflate") {
+		return
+	}
+	resp.Body = &decompressReader{rc: resp.Body, enc: enc}
+	resp.ContentLength = -1
+	resp.Headers.Del("Content-Encoding")
+	resp.Headers.Del("Content-Length")
+	resp.Uncompressed = true
+}
+
+// decompressReader decompresses a body as it's read. The decompressor is only set up on the first Read,
+// since it reads the gzip or zlib header straight away, and Do shouldn't wait on that.
+type decompressReader struct {
+	rc  io.ReadCloser
+	enc string
+	zr  io.Reader
+	err error
+}
+
+func (d *decompressReader) Read(p []byte) (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	if d.zr == nil {
+		var err error
+		if d.enc == "deflate" {
+			d.zr, err = zlib.NewReader(d.rc)
+		} else {
+			d.zr, err = gzip.NewReader(d.rc)
+		}
+		if err != nil {
+			d.err = fmt.Errorf("http: decompressing %s body: %w", d.enc, err)
+			return 0, d.err
+		}
+	}
+	n, err := d.zr.Read(p)
+	if err == io.EOF {
+		// the compressed data's done, but the body might not have said so yet, e.g a chunked body's last chunk.
+		// Reading that lets the connection be reused.
+		io.CopyN(io.Discard, d.rc, 512)
+	}
+	if err != nil {
+		d.err = err
+	}
+	return n, err
+}
+
+func (d *decompressReader) Close() error { return d.rc.Close() }
@@ -0,0 +1,231 @@
+package http
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	for accept, want := range map[string]string{
+		"":                              "",
+		"gzip":                          "gzip",
+		"deflate":                       "deflate",
+		"gzip, deflate":                 "gzip",
+		"deflate, gzip":                 "gzip", // a tie; the server's preference wins.
+		"gzip;q=0.5, deflate":           "deflate",
+		"deflate;q=0.5, gzip;q=0.8":     "gzip",
+		"GZIP;Q=0.2":                    "gzip",
+		"x-gzip":                        "gzip",
+		"br":                            "",
+		"*":                             "gzip",
+		"*;q=0":                         "",
+		"gzip;q=0, *":                   "deflate",
+		"identity":                      "",
+		"gzip;q=2":                      "", // malformed, so 0.
+		"gzip;q=0.001, deflate;q=0.002": "deflate",
+	} {
+		if got := negotiateEncoding([]string{accept}, "gzip", "deflate"); got != want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", accept, got, want)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	long := strings.Repeat("hello, world! ", 100)
+	for name, tt := range map[string]struct {
+		method      string
+		accept      string
+		contentType string
+		body        string
+		stream      bool
+		partial     bool // a 206, with a Content-Range
+		bodiless    bool // a response to HEAD that only has the Content-Length of the body it left out
+		wantEnc     string
+		wantVary    bool
+	}{
+		"gzip":             {accept: "gzip", contentType: "text/html; charset=utf-8", body: long, wantEnc: "gzip", wantVary: true},
+		"deflate":          {accept: "deflate", contentType: "application/json", body: long, wantEnc: "deflate", wantVary: true},
+		"streamed":         {accept: "gzip", contentType: "text/plain", body: long, stream: true, wantEnc: "gzip", wantVary: true},
+		"streamed, small":  {accept: "gzip", contentType: "text/plain", body: "hi", stream: true, wantEnc: "gzip", wantVary: true},
+		"not accepted":     {accept: "", contentType: "text/plain", body: long, wantVary: true},
+		"too small":        {accept: "gzip", contentType: "text/plain", body: "hi", wantVary: true},
+		"not compressible": {accept: "gzip", contentType: "image/png", body: long},
+		"no content type":  {accept: "gzip", body: long},
+		"partial":          {accept: "gzip", contentType: "text/plain", body: long, partial: true},
+		"HEAD":             {method: "HEAD", accept: "gzip", contentType: "text/plain", body: long, wantEnc: "gzip", wantVary: true},
+		"HEAD, bodiless":   {method: "HEAD", accept: "gzip", contentType: "text/plain", body: long, bodiless: true, wantEnc: "gzip", wantVary: true},
+		"HEAD, small":      {method: "HEAD", accept: "gzip", contentType: "text/plain", body: "hi", bodiless: true, wantVary: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			h := Compress(64)(HandlerFunc(func(r *Request) *Response {
+				resp, _ := NewResponse(200, tt.body)
+				if tt.partial {
+					resp.StatusCode = 206
+					resp.Headers.Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(tt.body)-1, 2*len(tt.body)))
+				}
+				if tt.bodiless {
+					resp.Body, resp.ContentLength = nil, 0
+				}
+				if tt.stream {
+					resp.Headers.Del("Content-Length")
+					resp.Body, resp.ContentLength = io.NopCloser(strings.NewReader(tt.body)), -1
+				}
+				if tt.contentType != "" {
+					resp.Headers.Set("Content-Type", tt.contentType)
+				}
+				return resp
+			}))
+			method := tt.method
+			if method == "" {
+				method = "GET"
+			}
+			req, _ := NewRequest(method, "/", "localhost", "")
+			if tt.accept != "" {
+				req.Headers.Set("Accept-Encoding", tt.accept)
+			}
+			resp := h.ServeHTTP(req)
+
+			if got := resp.Headers.Get("Content-Encoding"); got != tt.wantEnc {
+				t.Errorf("Content-Encoding is %q, want %q", got, tt.wantEnc)
+			}
+			if got := hasToken(resp.Headers, "Vary", "Accept-Encoding"); got != tt.wantVary {
+				t.Errorf("Vary: Accept-Encoding is %v, want %v", got, tt.wantVary)
+			}
+			if tt.bodiless {
+				if cl := resp.Headers.Get("Content-Length"); tt.wantEnc != "" && cl != "" {
+					t.Errorf("got Content-Length %s, but the compressed length isn't known", cl)
+				}
+				return
+			}
+			raw := bodyOf(t, resp)
+			if cl := resp.Headers.Get("Content-Length"); tt.stream && tt.wantEnc != "" && cl != "" {
+				t.Errorf("streamed body has Content-Length %s, want none", cl)
+			}
+			if got := decompress(t, tt.wantEnc, raw); got != tt.body {
+				t.Errorf("got body %q, want %q", got, tt.body)
+			}
+		})
+	}
+}
+
+func TestCompressHEAD(t *testing.T) {
+	long := strings.Repeat("hello, world! ", 100)
+	for name, h := range map[string]Handler{
+		"in memory": HandlerFunc(func(r *Request) *Response {
+			resp, _ := NewResponse(200, long)
+			return resp.WithHeader("Content-Type", "text/plain")
+		}),
+		"ServeContent": HandlerFunc(func(r *Request) *Response {
+			return ServeContent(r, "hello.txt", time.Time{}, strings.NewReader(long))
+		}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			addr := startServer(t, &Server{Handler: Compress(64)(h)})
+			headers := make(map[string]string) // the status line and headers, as sent.
+			for _, method := range []string{"GET", "HEAD"} {
+				conn, err := net.Dial("tcp", addr)
+				if err != nil {
+					t.Fatalf("dial: %v", err)
+				}
+				conn.Write([]byte(method + " / HTTP/1.1\r\nHost: localhost\r\nAccept-Encoding: gzip\r\nConnection: close\r\n\r\n"))
+				raw, err := io.ReadAll(conn)
+				conn.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				headers[method], _, _ = strings.Cut(string(raw), "\r\n\r\n")
+			}
+			if headers["HEAD"] != headers["GET"] {
+				t.Errorf("got headers %q for HEAD, but %q for GET", headers["HEAD"], headers["GET"])
+			}
+			if !strings.Contains(headers["HEAD"], "Content-Encoding: gzip") {
+				t.Errorf("got headers %q, want them gzipped", headers["HEAD"])
+			}
+		})
+	}
+}
+
+// decompress decodes a body in the content coding enc, which is "" if it isn't encoded.
+func decompress(t *testing.T, enc, body string) string {
+	t.Helper()
+	var zr io.Reader
+	var err error
+	switch enc {
+	case "":
+		return body
+	case "gzip":
+		zr, err = gzip.NewReader(strings.NewReader(body))
+	case "deflate":
+		zr, err = zlib.NewReader(strings.NewReader(body))
+	}
+	if err != nil {
+		t.Fatalf("decompressing %s: %v", enc, err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("decompressing %s: %v", enc, err)
+	}
+	return string(b)
+}
+
+func TestClientDecompress(t *testing.T) {
+	long := strings.Repeat("hello, world! ", 100)
+	addr := startServer(t, &Server{Handler: Compress(0)(HandlerFunc(func(r *Request) *Response {
+		resp, _ := NewResponse(200, long)
+		if r.Path == "/stream" {
+			resp.Headers.Del("Content-Length")
+			resp.Body, resp.ContentLength = io.NopCloser(strings.NewReader(long)), -1
+		}
+		return resp.WithHeader("Content-Type", "text/plain")
+	}))})
+
+	for name, tt := range map[string]struct {
+		client           *Client
+		path, accept     string
+		wantUncompressed bool
+		wantEnc          string
+	}{
+		"gzip":                 {client: new(Client), path: "/", wantUncompressed: true},
+		"streamed":             {client: new(Client), path: "/stream", wantUncompressed: true},
+		"asked for deflate":    {client: new(Client), path: "/", accept: "deflate", wantEnc: "deflate"},
+		"compression disabled": {client: &Client{DisableCompression: true}, path: "/"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 2; i++ { // twice, so the second goes over a reused connection.
+				req, _ := NewRequest("GET", tt.path, addr, "")
+				if tt.accept != "" {
+					req.Headers.Set("Accept-Encoding", tt.accept)
+				}
+				resp, err := tt.client.Do(context.Background(), req)
+				if err != nil {
+					t.Fatalf("Do: %v", err)
+				}
+				if resp.Uncompressed != tt.wantUncompressed {
+					t.Errorf("Uncompressed is %v, want %v", resp.Uncompressed, tt.wantUncompressed)
+				}
+				if got := resp.Headers.Get("Content-Encoding"); got != tt.wantEnc {
+					t.Errorf("Content-Encoding is %q, want %q", got, tt.wantEnc)
+				}
+				if got := decompress(t, tt.wantEnc, bodyOf(t, resp)); got != long {
+					t.Errorf("got body %q, want %q", got, long)
+				}
+			}
+		})
+	}
+}
+
+func TestDecompressBadBody(t *testing.T) {
+	resp := &Response{StatusCode: 200, Headers: Headers{{"Content-Encoding", "gzip"}}}
+	resp.SetBody([]byte("not gzip"))
+	decompressBody(resp)
+	if _, err := io.ReadAll(resp.Body); err == nil || !strings.Contains(err.Error(), "decompressing gzip") {
+		t.Errorf("got error %v, want a decompression error", err)
+	}
+}
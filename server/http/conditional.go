@@ -0,0 +1,207 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"time"
+)
+
+// Conditional requests (RFC 9110 section 13) let a client that already has a copy of a representation ask for it
+// only if it has changed, or make a change only if nobody else has changed it in the meantime. The copy is
+// identified by a validator: its ETag, an opaque tag that changes whenever the representation does, or, failing
+// that, its Last-Modified date.
+//
+//	If-None-Match: "xyzzy"            send it if it's no longer "xyzzy"; if it still is, a 304 Not Modified will do
+//	If-Modified-Since: <date>         send it if it changed after <date>; otherwise, a 304 Not Modified
+//	If-Match: "xyzzy"                 go ahead only if it's still "xyzzy"; otherwise, a 412 Precondition Failed
+//	If-Unmodified-Since: <date>       go ahead only if it didn't change after <date>; otherwise, a 412
+//	If-Range: "xyzzy"                 send the Range asked for if it's still "xyzzy"; otherwise, all of it
+//
+// For GET and HEAD, the Server evaluates them against the Response the Handler returns, using its ETag and
+// Last-Modified headers, before writing it: a handler only has to set those, e.g with the ETags middleware, and
+// clients polling for changes get a bodiless 304 instead of the same body over and over. Any other method changes
+// something, and by the time the handler returns, it has, and its response describes the result, not what the
+// conditions were about: it's up to the handler to call CheckPreconditions first. If-Range is up to whoever
+// handles the Range, e.g ServeContent.
+//
+// An ETag is strong if any change to the bytes changes it, and weak (marked by a "W/" prefix) if it only changes
+// when the meaning does, e.g it might stay the same when the body is compressed differently. A weak ETag is
+// good enough for If-None-Match, but not for If-Match or If-Range, which need byte-for-byte equality.
+
+// StrongETag returns a strong ETag for content, derived from its SHA-256 hash, e.g "\"n4bQgYhMfWWaL-qgxVrQFa\"".
+func StrongETag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+// WeakETag is like StrongETag, but returns a weak ETag, e.g "W/\"n4bQgYhMfWWaL-qgxVrQFa\"".
+func WeakETag(content []byte) string {
+	return "W/" + StrongETag(content)
+}
+
+// ETags returns middleware that sets an ETag on successful responses to GET and HEAD requests that don't have one
+// yet, computed from the body, so that the Server can answer conditional requests for them. Only bodies in memory
+// (see Response.SetBody) are tagged; streamed bodies are left alone. If weak, the ETags are weak ones.
+//
+// A strong ETag has to differ between the compressed and uncompressed forms of a body, so put ETags outside of
+// Compress in a Chain, where it sees the compressed body, or make the ETags weak.
+func ETags(weak bool) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(r *Request) *Response {
+			resp := next.ServeHTTP(r)
+			if resp == nil || (r.Method != "GET" && r.Method != "HEAD") || resp.StatusCode != 200 || resp.Headers.Has("ETag") {
+				return resp
+			}
+			mb, ok := resp.Body.(*memBody)
+			if !ok {
+				return resp
+			}
+			if weak {
+				resp.Headers.Set("ETag", WeakETag(mb.b))
+			} else {
+				resp.Headers.Set("ETag", StrongETag(mb.b))
+			}
+			return resp
+		})
+	}
+}
+
+// CheckPreconditions evaluates the If-Match, If-Unmodified-Since, If-None-Match and If-Modified-Since headers of r
+// against the current representation, which has the given ETag and modification time, either of which may be empty
+// (or zero) if it doesn't have one, in the order RFC 9110 section 13.2.2 says to. "*" matches it either way; if
+// there's no current representation at all, e.g for a PUT that creates one, use CheckPreconditionsMissing instead. It returns the status code to respond with
+// instead of going ahead with the request, either 304 (Not Modified) or 412 (Precondition Failed), or 0 to go ahead.
+//
+// The Server does this for successful responses to GET and HEAD. A handler for any other method, e.g a PUT or
+// DELETE that's guarded by If-Match, has to call it itself, before making the change.
+func CheckPreconditions(r *Request, etag string, modtime time.Time) int {
+	if r.Headers.Has("If-Match") {
+		if !matchETag(r.Headers.Values("If-Match"), etag, true) {
+			return 412
+		}
+	} else if t, ok := parseTime(r.Headers.Get("If-Unmodified-Since")); ok && !modtime.IsZero() && modtime.Truncate(time.Second).After(t) {
+		return 412
+	}
+	safe := r.Method == "GET" || r.Method == "HEAD"
+	if r.Headers.Has("If-None-Match") {
+		if !matchETag(r.Headers.Values("If-None-Match"), etag, false) {
+			return 0
+		}
+		if safe {
+			return 304
+		}
+		return 412
+	}
+	if t, ok := parseTime(r.Headers.Get("If-Modified-Since")); ok && safe && !modtime.IsZero() && !modtime.Truncate(time.Second).After(t) {
+		return 304
+	}
+	return 0
+}
+
+// applyPreconditions replaces resp with a 304 or 412 if the preconditions of r aren't met; see CheckPreconditions.
+// They only apply to successful responses to GET and HEAD: an error is an error whatever the client has cached,
+// and the response to anything else is about what the handler did, which it's too late to check.
+func applyPreconditions(r *Request, resp *Response) *Response {
+	if (r.Method != "GET" && r.Method != "HEAD") || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp
+	}
+	modtime, _ := parseTime(resp.Headers.Get("Last-Modified"))
+	status := CheckPreconditions(r, resp.Headers.Get("ETag"), modtime)
+	if status == 0 {
+		return resp
+	}
+	if resp.Body != nil {
+		resp.Body.Close()
+	}
+	if status == 412 {
+		return errorResponse(412)
+	}
+	// RFC 9110 section 15.4.5: a 304 carries the headers a 200 would have that a cache might need to update.
+	notModified := &Response{StatusCode: 304}
+	for _, h := range resp.Headers {
+		switch h.Key {
+		case "Cache-Control", "Content-Location", "Date", "Etag", "Expires", "Last-Modified", "Vary": // keys are in AsTitle form.
+			notModified.Headers.Add(h.Key, h.Value)
+		}
+	}
+	return notModified
+}
+
+// CheckPreconditionsMissing is like CheckPreconditions, but for a resource that has no current representation, e.g
+// one a PUT is about to create: If-Match fails, even with "*", and the other conditions are met, so it returns 412
+// or 0. "If-None-Match: *" is how a client asks to create something only if it doesn't exist yet.
+func CheckPreconditionsMissing(r *Request) int {
+	if r.Headers.Has("If-Match") {
+		return 412
+	}
+	return 0
+}
+
+// matchETag reports whether a current representation with the given ETag, "" if it has none, matches the entity
+// tags in the given If-Match or If-None-Match headers: "*" matches any, and otherwise etag has to be one of them.
+// If strong, weak ETags never match (RFC 9110 section 8.8.3.2).
+func matchETag(headers []string, etag string, strong bool) bool {
+	for _, h := range headers {
+		for _, tag := range splitETags(h) {
+			if tag == "*" {
+				return true
+			}
+			if etag == "" {
+				continue
+			}
+			if strong && (strings.HasPrefix(tag, "W/") || strings.HasPrefix(etag, "W/")) {
+				continue
+			}
+			if strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splitETags splits a comma-separated list of entity tags, e.g `"a", W/"b,c"` into `"a"` and `W/"b,c"`.
+// Since an entity tag may itself contain commas, it can't just split on them.
+func splitETags(s string) []string {
+	var tags []string
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return tags
+		}
+		start := 0
+		if strings.HasPrefix(s, "W/") {
+			start = 2
+		}
+		if !strings.HasPrefix(s[start:], `"`) {
+			// not a quoted tag: "*", or something malformed we'll take up to the next comma.
+			tag, rest, _ := strings.Cut(s, ",")
+			tags = append(tags, strings.TrimSpace(tag))
+			s = rest
+			continue
+		}
+		end := strings.IndexByte(s[start+1:], '"')
+		if end < 0 {
+			return append(tags, s) // unterminated; it can't match anything.
+		}
+		end += start + 2
+		tags = append(tags, s[:end])
+		s = s[end:]
+	}
+}
+
+// ifRangeMatches reports whether the If-Range header of r, if any, still matches a representation with the
+// given ETag and modification time, so its Range can be honored. A date only matches if it's exactly the
+// modification time; an ETag only if it's the same strong ETag (RFC 9110 section 13.1.5).
+func ifRangeMatches(r *Request, etag string, modtime time.Time) bool {
+	v := strings.TrimSpace(r.Headers.Get("If-Range"))
+	switch {
+	case v == "":
+		return true
+	case strings.HasPrefix(v, `"`) || strings.HasPrefix(v, "W/"):
+		return etag != "" && !strings.HasPrefix(v, "W/") && !strings.HasPrefix(etag, "W/") && v == etag
+	}
+	t, ok := parseTime(v)
+	return ok && !modtime.IsZero() && modtime.Truncate(time.Second).Equal(t)
+}
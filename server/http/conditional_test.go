@@ -0,0 +1,212 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckPreconditions(t *testing.T) {
+	modTime := time.Date(2024, 5, 6, 7, 8, 9, 500, time.UTC) // sub-second part is lost in headers.
+	const (
+		before = "Mon, 06 May 2024 07:08:08 GMT"
+		same   = "Mon, 06 May 2024 07:08:09 GMT"
+		after  = "Mon, 06 May 2024 07:08:10 GMT"
+	)
+	for name, tt := range map[string]struct {
+		method  string
+		headers Headers
+		etag    string
+		want    int
+	}{
+		"no conditions":               {"GET", nil, `"a"`, 0},
+		"If-None-Match hit":           {"GET", Headers{{"If-None-Match", `"a"`}}, `"a"`, 304},
+		"If-None-Match miss":          {"GET", Headers{{"If-None-Match", `"b"`}}, `"a"`, 0},
+		"If-None-Match list":          {"GET", Headers{{"If-None-Match", `"b", W/"a"`}}, `"a"`, 304},
+		"If-None-Match comma in tag":  {"GET", Headers{{"If-None-Match", `"a,b"`}}, `"b"`, 0},
+		"If-None-Match weak":          {"HEAD", Headers{{"If-None-Match", `"a"`}}, `W/"a"`, 304},
+		"If-None-Match star":          {"GET", Headers{{"If-None-Match", "*"}}, `"a"`, 304},
+		"If-None-Match star, no etag": {"GET", Headers{{"If-None-Match", "*"}}, "", 304},
+		"If-None-Match tag, no etag":  {"GET", Headers{{"If-None-Match", `"a"`}}, "", 0},
+		"If-None-Match on PUT":        {"PUT", Headers{{"If-None-Match", `"a"`}}, `"a"`, 412},
+		"If-None-Match beats date":    {"GET", Headers{{"If-None-Match", `"b"`}, {"If-Modified-Since", after}}, `"a"`, 0},
+		"If-Modified-Since same":      {"GET", Headers{{"If-Modified-Since", same}}, "", 304},
+		"If-Modified-Since after":     {"GET", Headers{{"If-Modified-Since", after}}, "", 304},
+		"If-Modified-Since before":    {"GET", Headers{{"If-Modified-Since", before}}, "", 0},
+		"If-Modified-Since bad date":  {"GET", Headers{{"If-Modified-Since", "yesterday"}}, "", 0},
+		"If-Modified-Since on POST":   {"POST", Headers{{"If-Modified-Since", after}}, "", 0},
+		"If-Match hit":                {"PUT", Headers{{"If-Match", `"x", "a"`}}, `"a"`, 0},
+		"If-Match miss":               {"PUT", Headers{{"If-Match", `"b"`}}, `"a"`, 412},
+		"If-Match weak":               {"PUT", Headers{{"If-Match", `W/"a"`}}, `W/"a"`, 412},
+		"If-Match star":               {"PUT", Headers{{"If-Match", "*"}}, `"a"`, 0},
+		"If-Match star, no etag":      {"GET", Headers{{"If-Match", "*"}}, "", 0},
+		"If-Match tag, no etag":       {"PUT", Headers{{"If-Match", `"a"`}}, "", 412},
+		"If-Match beats date":         {"PUT", Headers{{"If-Match", `"a"`}, {"If-Unmodified-Since", before}}, `"a"`, 0},
+		"If-Unmodified-Since before":  {"PUT", Headers{{"If-Unmodified-Since", before}}, "", 412},
+		"If-Unmodified-Since same":    {"PUT", Headers{{"If-Unmodified-Since", same}}, "", 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, _ := NewRequest(tt.method, "/", "localhost", "")
+			req.Headers = append(req.Headers, tt.headers...)
+			if got := CheckPreconditions(req, tt.etag, modTime); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestETags(t *testing.T) {
+	handler := func(status int, body string) Handler {
+		return HandlerFunc(func(r *Request) *Response {
+			resp, _ := NewResponse(status, body)
+			return resp
+		})
+	}
+	body := []byte("hello")
+	for name, tt := range map[string]struct {
+		weak    bool
+		method  string
+		handler Handler
+		want    string
+	}{
+		"strong":    {false, "GET", handler(200, "hello"), StrongETag(body)},
+		"weak":      {true, "HEAD", handler(200, "hello"), WeakETag(body)},
+		"POST":      {false, "POST", handler(200, "hello"), ""},
+		"not found": {false, "GET", handler(404, ""), ""},
+		"already set": {false, "GET", HandlerFunc(func(r *Request) *Response {
+			resp, _ := NewResponse(200, "hello")
+			return resp.WithHeader("ETag", `"v1"`)
+		}), `"v1"`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, _ := NewRequest(tt.method, "/", "localhost", "")
+			resp := ETags(tt.weak)(tt.handler).ServeHTTP(req)
+			if got := resp.Headers.Get("ETag"); got != tt.want {
+				t.Errorf("got ETag %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if a, b := StrongETag([]byte("a")), StrongETag([]byte("b")); a == b || !strings.HasPrefix(a, `"`) {
+		t.Errorf("got ETags %s and %s for different content", a, b)
+	}
+}
+
+func TestServerConditional(t *testing.T) {
+	s := &Server{Handler: ETags(false)(HandlerFunc(func(r *Request) *Response {
+		resp, _ := NewResponse(200, "dashboard")
+		return resp.WithHeader("Last-Modified", "Mon, 06 May 2024 07:08:09 GMT").WithHeader("Cache-Control", "no-cache").WithHeader("X-Other", "1")
+	}))}
+	addr := startServer(t, s)
+	etag := StrongETag([]byte("dashboard"))
+	for name, tt := range map[string]struct {
+		header      string
+		wantStatus  int
+		wantBody    string
+		wantHeaders Headers
+	}{
+		"unconditional": {"", 200, "dashboard", nil},
+		"not modified":  {"If-None-Match: " + etag, 304, "", Headers{{"Last-Modified", "Mon, 06 May 2024 07:08:09 GMT"}, {"Cache-Control", "no-cache"}, {"Etag", etag}}},
+		"modified":      {`If-None-Match: "old"`, 200, "dashboard", nil},
+		"by date":       {"If-Modified-Since: Mon, 06 May 2024 07:08:09 GMT", 304, "", Headers{{"Last-Modified", "Mon, 06 May 2024 07:08:09 GMT"}, {"Cache-Control", "no-cache"}, {"Etag", etag}}},
+		"failed":        {`If-Match: "old"`, 412, "Precondition Failed", nil},
+	} {
+		t.Run(name, func(t *testing.T) {
+			raw := "GET / HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n"
+			if tt.header != "" {
+				raw += tt.header + "\r\n"
+			}
+			resp := roundTrip(t, addr, raw+"\r\n")
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := bodyOf(t, resp); got != tt.wantBody {
+				t.Errorf("got body %q, want %q", got, tt.wantBody)
+			}
+			if tt.wantHeaders != nil {
+				got := resp.Headers.without("Connection")
+				if !reflect.DeepEqual(got, tt.wantHeaders) {
+					t.Errorf("got headers %v, want %v", got, tt.wantHeaders)
+				}
+			}
+		})
+	}
+}
+
+func TestServerGuardedWrite(t *testing.T) {
+	for name, tt := range map[string]struct {
+		path, header string
+		wantStatus   int
+		wantETag     string
+	}{
+		"update":            {"/doc", `If-Match: "v1"`, 200, `"v2"`},
+		"lost update":       {"/doc", `If-Match: "v0"`, 412, ""},
+		"create":            {"/new", "If-None-Match: *", 201, `"v1"`},
+		"already created":   {"/doc", "If-None-Match: *", 412, ""},
+		"nothing to update": {"/new", "If-Match: *", 412, ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			versions := map[string]int{"/doc": 1} // 0 for those that don't exist (yet).
+			etag := func(path string) string { return fmt.Sprintf(`"v%d"`, versions[path]) }
+			addr := startServer(t, &Server{Handler: HandlerFunc(func(r *Request) *Response {
+				// the response is about the new version, which the conditions weren't: the Server mustn't check them again.
+				check := func(r *Request) int { return CheckPreconditions(r, etag(r.Path), time.Time{}) }
+				if versions[r.Path] == 0 {
+					check = CheckPreconditionsMissing
+				}
+				if status := check(r); status != 0 {
+					return errorResponse(status)
+				}
+				status := 200
+				if versions[r.Path] == 0 {
+					status = 201
+				}
+				versions[r.Path]++
+				resp, _ := NewResponse(status, "")
+				return resp.WithHeader("ETag", etag(r.Path))
+			})})
+			resp := roundTrip(t, addr, "PUT "+tt.path+" HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n"+tt.header+"\r\nContent-Length: 0\r\n\r\n")
+			bodyOf(t, resp)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := resp.Headers.Get("ETag"); got != tt.wantETag {
+				t.Errorf("got ETag %q, want %q", got, tt.wantETag)
+			}
+		})
+	}
+}
+
+func TestServeContentIfRange(t *testing.T) {
+	modTime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	etag := contentETag(modTime, 10)
+	for name, tt := range map[string]struct {
+		ifRange    string
+		wantStatus int
+	}{
+		"no If-Range":  {"", 206},
+		"same ETag":    {etag, 206},
+		"other ETag":   {`"old"`, 200},
+		"weak ETag":    {"W/" + etag, 200},
+		"same date":    {"Mon, 06 May 2024 07:08:09 GMT", 206},
+		"earlier date": {"Mon, 06 May 2024 07:08:08 GMT", 200},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, _ := NewRequest("GET", "/digits.txt", "localhost", "")
+			req.Headers.Set("Range", "bytes=0-1")
+			if tt.ifRange != "" {
+				req.Headers.Set("If-Range", tt.ifRange)
+			}
+			resp := ServeContent(req, "digits.txt", modTime, bytes.NewReader([]byte("0123456789")))
+			bodyOf(t, resp)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := resp.Headers.Get("ETag"); got != etag {
+				t.Errorf("got ETag %q, want %q", got, etag)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A client about to send a large body can ask first (RFC 9110 section 10.1.1): it sends the headers with
+// "Expect: 100-continue", and waits for the server to say "100 Continue" before sending the body. If the server
+// answers with a final response instead, e.g a 401 or a 413, the body never has to be sent at all.
+//
+// On the Server, the 100 Continue is sent when the handler first reads the body, so a handler that turns the
+// request down without reading it, e.g because it isn't authorized, turns it down before the body is sent. The
+// connection is then closed, since there's no telling whether the client went on to send the body anyway.
+// A Content-Length over the Parser's MaxBodyBytes gets a 413 straight away, and any other expectation a
+// 417 Expectation Failed; the handler isn't called for either.
+//
+// The Client waits up to ExpectContinueTimeout for the 100 Continue, and then sends the body anyway, since
+// the server might not know about 100-continue at all.
+
+// continueReader is the body of a request that expects a 100 Continue: it sends one on the first Read.
+type continueReader struct {
+	rc io.ReadCloser
+	bw *bufio.Writer
+
+	mu      sync.Mutex
+	sent    bool  // the 100 Continue has been sent, so the body's on its way.
+	stopped bool  // the handler's done: it's too late to send one.
+	err     error // from sending it
+}
+
+func (cr *continueReader) Read(p []byte) (int, error) {
+	cr.mu.Lock()
+	if !cr.sent && !cr.stopped {
+		cr.sent = true
+		if _, cr.err = cr.bw.WriteString("HTTP/1.1 100 Continue\r\n\r\n"); cr.err == nil {
+			cr.err = cr.bw.Flush()
+		}
+	}
+	err := cr.err
+	cr.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	return cr.rc.Read(p)
+}
+
+func (cr *continueReader) Close() error { return cr.rc.Close() }
+
+// stop makes sure no 100 Continue goes out once the handler has returned, e.g from a handler that's given up on
+// by the Timeout middleware, but still reading, as the response is written. It reports whether one was sent.
+func (cr *continueReader) stop() bool {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.stopped = true
+	return cr.sent && cr.err == nil
+}
+
+// unknownExpectation reports whether r has an Expect header we can't meet, i.e. anything but 100-continue.
+func unknownExpectation(r *Request) bool {
+	for _, v := range r.Headers.Values("Expect") {
+		for _, e := range strings.Split(v, ",") {
+			if e = strings.TrimSpace(e); e != "" && !strings.EqualFold(e, "100-continue") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// awaitContinue waits up to ExpectContinueTimeout for the server to answer a request whose headers, with
+// "Expect: 100-continue", have been sent. It returns nil if the body should be sent next: the server said
+// 100 Continue, or nothing at all in time. Otherwise, the body isn't wanted, and it returns the final response.
+func (c *Client) awaitContinue(ctx context.Context, pc *persistConn, req *Request) (*Response, error) {
+	for i := 0; i <= maxInterimResponses; i++ {
+		pc.conn.SetReadDeadline(time.Now().Add(c.ExpectContinueTimeout))
+		_, err := pc.br.Peek(1)
+		pc.conn.SetReadDeadline(time.Time{})
+		if ctx.Err() != nil {
+			return nil, ctx.Err() // the deadline might've been watchContext's, which we just cleared.
+		}
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("http: reading response: %w", err)
+		}
+		resp, err := c.Parser.readOneResponse(pc.br, req)
+		switch {
+		case err != nil:
+			return nil, fmt.Errorf("http: reading response: %w", err)
+		case resp.StatusCode == 100:
+			return nil, nil
+		case resp.StatusCode >= 200 || resp.StatusCode == 101:
+			return resp, nil
+		}
+		// some other interim response, e.g 103 Early Hints; keep waiting.
+	}
+	return nil, fmt.Errorf("http: reading response: %w", errTooManyInterim)
+}
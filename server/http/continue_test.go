@@ -0,0 +1,188 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerExpectContinue(t *testing.T) {
+	s := &Server{Parser: Parser{MaxBodyBytes: 100}, Handler: HandlerFunc(func(r *Request) *Response {
+		if r.Headers.Get("Authorization") == "" {
+			resp, _ := NewResponse(401, "")
+			return resp
+		}
+		body, _ := r.BodyBytes()
+		resp, _ := NewResponse(200, "got "+string(body))
+		return resp
+	})}
+	addr := startServer(t, s)
+
+	for name, tt := range map[string]struct {
+		header      string // sent first, on its own
+		body        string // sent after the 100 Continue, if it comes
+		wantResp    string // the start of everything the server sends back
+		wantClosed  bool
+		wantNoReply bool // the server shouldn't answer the header alone
+	}{
+		"continue": {
+			header:   "PUT /upload HTTP/1.1\r\nHost: localhost\r\nAuthorization: yes\r\nExpect: 100-continue\r\nContent-Length: 5\r\n\r\n",
+			body:     "hello",
+			wantResp: "HTTP/1.1 100 Continue\r\n\r\nHTTP/1.1 200 OK\r\nContent-Length: 9\r\n\r\ngot hello",
+		},
+		"rejected": {
+			header:     "PUT /upload HTTP/1.1\r\nHost: localhost\r\nExpect: 100-continue\r\nContent-Length: 5\r\n\r\n",
+			wantResp:   "HTTP/1.1 401 Unauthorized\r\n",
+			wantClosed: true,
+		},
+		"too large": {
+			header:     "PUT /upload HTTP/1.1\r\nHost: localhost\r\nAuthorization: yes\r\nExpect: 100-continue\r\nContent-Length: 500\r\n\r\n",
+			wantResp:   "HTTP/1.1 413 Request Entity Too Large\r\n",
+			wantClosed: true,
+		},
+		"unknown expectation": {
+			header:     "PUT /upload HTTP/1.1\r\nHost: localhost\r\nAuthorization: yes\r\nExpect: 200-ok\r\nContent-Length: 5\r\n\r\n",
+			wantResp:   "HTTP/1.1 417 Expectation Failed\r\n",
+			wantClosed: true,
+		},
+		"HTTP/1.0 ignores it": {
+			header:      "PUT /upload HTTP/1.0\r\nHost: localhost\r\nAuthorization: yes\r\nExpect: 100-continue\r\nContent-Length: 5\r\n\r\n",
+			body:        "hello",
+			wantResp:    "HTTP/1.1 200 OK\r\nContent-Length: 9\r\nConnection: close\r\n\r\ngot hello",
+			wantClosed:  true,
+			wantNoReply: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			defer conn.Close()
+			conn.Write([]byte(tt.header))
+			br := bufio.NewReader(conn)
+			conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+			_, err = br.Peek(1)
+			if ne, ok := err.(net.Error); ok && ne.Timeout() != tt.wantNoReply {
+				t.Fatalf("got %v waiting for the first reply", err)
+			}
+			if tt.body != "" {
+				conn.Write([]byte(tt.body))
+			}
+			if !tt.wantClosed {
+				conn.Write([]byte("GET /next HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n"))
+			}
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			got, err := io.ReadAll(br)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(string(got), tt.wantResp) {
+				t.Errorf("got %q, want it to start with %q", got, tt.wantResp)
+			}
+			if !tt.wantClosed && !strings.Contains(string(got[len(tt.wantResp):]), "HTTP/1.1 401") {
+				t.Errorf("the next request on the connection wasn't answered; got %q", got)
+			}
+		})
+	}
+}
+
+func TestClientExpectContinue(t *testing.T) {
+	addr := startServer(t, &Server{Handler: HandlerFunc(func(r *Request) *Response {
+		if r.Path == "/private" {
+			resp, _ := NewResponse(401, "")
+			return resp
+		}
+		return echoRequest(r)
+	})})
+	// an old server, which knows nothing of 100-continue: it waits for the body, then answers.
+	old := listen(t)
+	go func() {
+		for {
+			conn, err := old.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				req, err := ReadRequest(bufio.NewReader(conn))
+				if err != nil {
+					return
+				}
+				body, _ := req.BodyBytes()
+				resp, _ := NewResponse(200, "old: "+string(body))
+				resp.Headers.Set("Connection", "close")
+				resp.WriteTo(conn)
+			}()
+		}
+	}()
+	// a server that sends interim responses of its own before its 100 Continue.
+	hints := stallingServer(t, "HTTP/1.1 103 Early Hints\r\nLink: </style.css>; rel=preload\r\n\r\n"+
+		"HTTP/1.1 100 Continue\r\n\r\nHTTP/1.1 204 No Content\r\n\r\n")
+
+	for name, tt := range map[string]struct {
+		addr, path  string
+		timeout     time.Duration
+		wantStatus  int
+		wantBody    string
+		maxDuration time.Duration
+	}{
+		"continue":        {addr, "/upload", time.Minute, 200, "PUT /upload auth= cookie=: hello", time.Second},
+		"rejected":        {addr, "/private", time.Minute, 401, "Unauthorized", time.Second},
+		"old server":      {old.Addr().String(), "/upload", 50 * time.Millisecond, 200, "old: hello", time.Second},
+		"interim":         {hints, "/upload", time.Minute, 204, "", time.Second},
+		"no waiting":      {addr, "/upload", 0, 200, "PUT /upload auth= cookie=: hello", time.Second},
+		"rejected anyway": {addr, "/private", 0, 401, "Unauthorized", time.Second},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := &Client{ExpectContinueTimeout: tt.timeout}
+			defer c.CloseIdleConnections()
+			req, _ := NewRequest("PUT", tt.path, tt.addr, "hello")
+			req.Headers.Set("Expect", "100-continue")
+			start := time.Now()
+			resp, err := c.Do(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := bodyOf(t, resp); resp.StatusCode != tt.wantStatus || got != tt.wantBody {
+				t.Errorf("got %d %q, want %d %q", resp.StatusCode, got, tt.wantStatus, tt.wantBody)
+			}
+			if d := time.Since(start); d > tt.maxDuration {
+				t.Errorf("took %v", d)
+			}
+		})
+	}
+}
+
+func TestReadResponseSkipsInterim(t *testing.T) {
+	for name, tt := range map[string]struct {
+		raw        string
+		wantStatus int
+		wantErr    bool
+	}{
+		"100":      {"HTTP/1.1 100 Continue\r\n\r\nHTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok", 200, false},
+		"several":  {"HTTP/1.1 100 Continue\r\n\r\nHTTP/1.1 103 Early Hints\r\nLink: </a.css>\r\n\r\nHTTP/1.1 404 Not Found\r\nContent-Length: 2\r\n\r\nok", 404, false},
+		"101":      {"HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\n\r\n", 101, false},
+		"too many": {strings.Repeat("HTTP/1.1 100 Continue\r\n\r\n", maxInterimResponses+1) + "HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n", 0, true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp, err := ParseResponse(tt.raw)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %d, want an error", resp.StatusCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package http
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cookies (RFC 6265) go from server to client in Set-Cookie headers, one cookie per header, with its attributes:
+//
+//	Set-Cookie: session=abc123; Path=/; Max-Age=3600; HttpOnly; Secure; SameSite=Lax
+//
+// and come back from client to server in a single Cookie header, as plain name=value pairs:
+//
+//	Cookie: session=abc123; theme=dark
+
+// Cookie is an HTTP cookie: a name and value, plus the attributes the server sets in Set-Cookie.
+// The attributes only matter on the way out; cookies read from a request only have a Name and Value.
+type Cookie struct {
+	Name  string
+	Value string
+
+	Path     string    // the path prefix the cookie is sent for; if empty, the client decides from the request path
+	Domain   string    // the domain the cookie is sent to, subdomains included; if empty, only the host that set it
+	Expires  time.Time // when the cookie expires; if zero (and there's no MaxAge), it lasts for the session
+	MaxAge   int       // the cookie's lifetime in seconds; 0 means unset, negative means delete it now ("Max-Age=0")
+	Secure   bool      // only send the cookie over https
+	HttpOnly bool      // don't let scripts see the cookie
+	SameSite SameSite  // whether to send the cookie with cross-site requests
+}
+
+// SameSite controls whether a cookie is sent with cross-site requests. See the SameSite attribute of
+// the RFC 6265bis draft.
+type SameSite int
+
+const (
+	SameSiteDefault SameSite = iota // no SameSite attribute; browsers treat it as Lax
+	SameSiteLax
+	SameSiteStrict
+	SameSiteNone
+)
+
+func (s SameSite) String() string {
+	switch s {
+	case SameSiteLax:
+		return "Lax"
+	case SameSiteStrict:
+		return "Strict"
+	case SameSiteNone:
+		return "None"
+	default:
+		return ""
+	}
+}
+
+// String returns the cookie as it'd go in a Set-Cookie header, or, if it has only a Name and Value,
+// in a Cookie header. It returns "" if the cookie's name or value can't be sent; attributes that
+// can't be sent are left out.
+func (c *Cookie) String() string {
+	if !isToken(c.Name) || !validCookieValue(c.Value) {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(c.Name)
+	b.WriteByte('=')
+	b.WriteString(quoteCookieValue(c.Value))
+	if c.Path != "" && validCookieAttr(c.Path) {
+		b.WriteString("; Path=" + c.Path)
+	}
+	if d := strings.TrimPrefix(c.Domain, "."); d != "" && validCookieDomain(d) {
+		b.WriteString("; Domain=" + d)
+	}
+	if !c.Expires.IsZero() {
+		b.WriteString("; Expires=" + c.Expires.UTC().Format(TimeFormat))
+	}
+	switch {
+	case c.MaxAge > 0:
+		b.WriteString("; Max-Age=" + strconv.Itoa(c.MaxAge))
+	case c.MaxAge < 0:
+		b.WriteString("; Max-Age=0")
+	}
+	if c.HttpOnly {
+		b.WriteString("; HttpOnly")
+	}
+	if c.Secure {
+		b.WriteString("; Secure")
+	}
+	if c.SameSite != SameSiteDefault {
+		b.WriteString("; SameSite=" + c.SameSite.String())
+	}
+	return b.String()
+}
+
+// Cookies parses the Cookie headers of the request. Malformed pairs are skipped.
+func (r *Request) Cookies() []*Cookie {
+	var cookies []*Cookie
+	for _, line := range r.Headers.Values("Cookie") {
+		for _, pair := range strings.Split(line, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok || !isToken(name) {
+				continue
+			}
+			if value, ok = unquoteCookieValue(value); ok {
+				cookies = append(cookies, &Cookie{Name: name, Value: value})
+			}
+		}
+	}
+	return cookies
+}
+
+// Cookie returns the named cookie from the request, or nil if there's none.
+func (r *Request) Cookie(name string) *Cookie {
+	for _, c := range r.Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// AddCookie adds the cookie's name and value to the request's Cookie header; its attributes aren't sent.
+// Invalid cookies are skipped.
+func (r *Request) AddCookie(c *Cookie) {
+	pair := (&Cookie{Name: c.Name, Value: c.Value}).String()
+	if pair == "" {
+		return
+	}
+	if prev := r.Headers.Get("Cookie"); prev != "" {
+		pair = prev + "; " + pair
+	}
+	r.Headers.Set("Cookie", pair)
+}
+
+// SetCookie adds a Set-Cookie header for the cookie. Invalid cookies are skipped.
+func (resp *Response) SetCookie(c *Cookie) {
+	if v := c.String(); v != "" {
+		resp.Headers.Add("Set-Cookie", v)
+	}
+}
+
+// Cookies parses the Set-Cookie headers of the response. Malformed cookies are skipped, as are malformed attributes.
+func (resp *Response) Cookies() []*Cookie {
+	var cookies []*Cookie
+	for _, line := range resp.Headers.Values("Set-Cookie") {
+		if c, ok := parseSetCookie(line); ok {
+			cookies = append(cookies, c)
+		}
+	}
+	return cookies
+}
+
+// parseSetCookie parses the value of a Set-Cookie header.
+func parseSetCookie(line string) (*Cookie, bool) {
+	parts := strings.Split(line, ";")
+	name, value, ok := strings.Cut(strings.TrimSpace(parts[0]), "=")
+	if !ok || !isToken(name) {
+		return nil, false
+	}
+	if value, ok = unquoteCookieValue(value); !ok {
+		return nil, false
+	}
+	c := &Cookie{Name: name, Value: value}
+	for _, attr := range parts[1:] {
+		key, val, _ := strings.Cut(strings.TrimSpace(attr), "=")
+		val = strings.TrimSpace(val)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "path":
+			c.Path = val
+		case "domain":
+			c.Domain = strings.ToLower(strings.TrimPrefix(val, "."))
+		case "expires":
+			if t, ok := parseCookieTime(val); ok {
+				c.Expires = t
+			}
+		case "max-age":
+			// RFC 6265 section 5.2.2: zero or negative means it's expired already.
+			if n, err := strconv.Atoi(val); err == nil && (val[0] == '-' || isDigit(val[0])) {
+				if n <= 0 {
+					n = -1
+				}
+				c.MaxAge = n
+			}
+		case "secure":
+			c.Secure = true
+		case "httponly":
+			c.HttpOnly = true
+		case "samesite":
+			switch strings.ToLower(val) {
+			case "lax":
+				c.SameSite = SameSiteLax
+			case "strict":
+				c.SameSite = SameSiteStrict
+			case "none":
+				c.SameSite = SameSiteNone
+			}
+		}
+	}
+	return c, true
+}
+
+// parseCookieTime parses an Expires attribute. Besides the usual header formats, servers often send
+// the Netscape format, with dashes, e.g "Wed, 09-Jun-2021 10:18:14 GMT".
+func parseCookieTime(s string) (time.Time, bool) {
+	if t, ok := parseTime(s); ok {
+		return t, true
+	}
+	t, err := time.Parse("Mon, 02-Jan-2006 15:04:05 GMT", s)
+	return t, err == nil
+}
+
+// validCookieValue reports whether v can be sent as a cookie value: cookie-octets (RFC 6265 section 4.1.1),
+// plus spaces and commas, which we send quoted, like net/http does.
+func validCookieValue(v string) bool {
+	for i := 0; i < len(v); i++ {
+		if !isCookieOctet(v[i]) && v[i] != ' ' && v[i] != ',' {
+			return false
+		}
+	}
+	return true
+}
+
+func quoteCookieValue(v string) string {
+	if strings.ContainsAny(v, " ,") {
+		return `"` + v + `"`
+	}
+	return v
+}
+
+// unquoteCookieValue strips the optional double quotes around a cookie value, reporting whether what's left is valid.
+func unquoteCookieValue(v string) (string, bool) {
+	if len(v) > 1 && v[0] == '"' && v[len(v)-1] == '"' {
+		v = v[1 : len(v)-1]
+	}
+	for i := 0; i < len(v); i++ {
+		if !isCookieOctet(v[i]) {
+			return "", false
+		}
+	}
+	return v, true
+}
+
+// isCookieOctet: US-ASCII characters excluding controls, whitespace, DQUOTE, comma, semicolon, and backslash.
+func isCookieOctet(c byte) bool {
+	return c > ' ' && c < 0x7f && c != '"' && c != ',' && c != ';' && c != '\\'
+}
+
+// validCookieAttr reports whether v can go in an attribute value: anything but controls and ';'.
+func validCookieAttr(v string) bool {
+	for i := 0; i < len(v); i++ {
+		if v[i] < ' ' || v[i] == 0x7f || v[i] == ';' {
+			return false
+		}
+	}
+	return true
+}
+
+// validCookieDomain reports whether d looks like a host name or IP address.
+func validCookieDomain(d string) bool {
+	for i := 0; i < len(d); i++ {
+		c := d[i]
+		if !isDigit(c) && !('a' <= lower(c) && lower(c) <= 'z') && c != '-' && c != '.' && c != ':' {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
@@ -0,0 +1,118 @@
+package http
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCookieString(t *testing.T) {
+	for name, tt := range map[string]struct {
+		cookie Cookie
+		want   string
+	}{
+		"plain":       {Cookie{Name: "a", Value: "1"}, "a=1"},
+		"empty value": {Cookie{Name: "a"}, "a="},
+		"quoted":      {Cookie{Name: "a", Value: "x y,z"}, `a="x y,z"`},
+		"all attributes": {
+			Cookie{
+				Name: "session", Value: "abc", Path: "/app", Domain: ".example.com",
+				Expires: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC), MaxAge: 3600,
+				Secure: true, HttpOnly: true, SameSite: SameSiteStrict,
+			},
+			"session=abc; Path=/app; Domain=example.com; Expires=Wed, 02 Jan 2030 03:04:05 GMT; Max-Age=3600; HttpOnly; Secure; SameSite=Strict",
+		},
+		"delete":       {Cookie{Name: "a", Value: "", MaxAge: -1}, "a=; Max-Age=0"},
+		"bad name":     {Cookie{Name: "a b", Value: "1"}, ""},
+		"bad value":    {Cookie{Name: "a", Value: "1;2"}, ""},
+		"bad path":     {Cookie{Name: "a", Value: "1", Path: "/;x"}, "a=1"},
+		"bad domain":   {Cookie{Name: "a", Value: "1", Domain: "exa mple.com"}, "a=1"},
+		"SameSite=Lax": {Cookie{Name: "a", Value: "1", SameSite: SameSiteLax}, "a=1; SameSite=Lax"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.cookie.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSetCookie(t *testing.T) {
+	for name, tt := range map[string]struct {
+		line string
+		want *Cookie // nil if it should be rejected
+	}{
+		"plain":  {"a=1", &Cookie{Name: "a", Value: "1"}},
+		"quoted": {`a="1"`, &Cookie{Name: "a", Value: "1"}},
+		"all attributes": {
+			"session=abc; path=/app; DOMAIN=.Example.com; expires=Wed, 02 Jan 2030 03:04:05 GMT; Max-Age=3600; httponly; secure; samesite=none",
+			&Cookie{
+				Name: "session", Value: "abc", Path: "/app", Domain: "example.com",
+				Expires: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC), MaxAge: 3600,
+				Secure: true, HttpOnly: true, SameSite: SameSiteNone,
+			},
+		},
+		"netscape expires": {"a=1; Expires=Wed, 02-Jan-2030 03:04:05 GMT", &Cookie{Name: "a", Value: "1", Expires: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)}},
+		"max-age=0":        {"a=1; Max-Age=0", &Cookie{Name: "a", Value: "1", MaxAge: -1}},
+		"bad attributes":   {"a=1; Max-Age=soon; Expires=tomorrow; SameSite=sometimes; Flavor=mint", &Cookie{Name: "a", Value: "1"}},
+		"no equals":        {"a", nil},
+		"bad name":         {"a b=1", nil},
+		"bad value":        {`a=1"2`, nil},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, ok := parseSetCookie(tt.line)
+			if tt.want == nil {
+				if ok {
+					t.Errorf("got %+v, want it rejected", got)
+				}
+				return
+			}
+			if !ok || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, %v, want %+v", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestCookies(t *testing.T) {
+	req, _ := NewRequest("GET", "/", "example.com", "")
+	req.Headers.Add("Cookie", `a=1; b="2"; bad; c d=3`)
+	req.AddCookie(&Cookie{Name: "e", Value: "5", Path: "/ignored"})
+	req.AddCookie(&Cookie{Name: "bad name", Value: "6"})
+
+	if got, want := req.Headers.Get("Cookie"), `a=1; b="2"; bad; c d=3; e=5`; got != want {
+		t.Errorf("Cookie header is %q, want %q", got, want)
+	}
+	want := []*Cookie{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}, {Name: "e", Value: "5"}}
+	if got := req.Cookies(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Cookies() = %+v, want %+v", got, want)
+	}
+	if c := req.Cookie("b"); c == nil || c.Value != "2" {
+		t.Errorf(`Cookie("b") = %+v, want b=2`, c)
+	}
+	if c := req.Cookie("z"); c != nil {
+		t.Errorf(`Cookie("z") = %+v, want nil`, c)
+	}
+}
+
+func TestResponseCookies(t *testing.T) {
+	resp, _ := NewResponse(200, "")
+	in := []*Cookie{
+		{Name: "a", Value: "1", Path: "/", HttpOnly: true},
+		{Name: "b", Value: "2", MaxAge: 60, Secure: true, SameSite: SameSiteLax},
+	}
+	for _, c := range in {
+		resp.SetCookie(c)
+	}
+	resp.SetCookie(&Cookie{Name: "", Value: "skipped"})
+	if got := len(resp.Headers.Values("Set-Cookie")); got != 2 {
+		t.Fatalf("got %d Set-Cookie headers, want 2", got)
+	}
+	parsed, err := ParseResponse(resp.String())
+	if err != nil {
+		t.Fatalf("ParseResponse: %v", err)
+	}
+	if got := parsed.Cookies(); !reflect.DeepEqual(got, in) {
+		t.Errorf("Cookies() = %+v, want %+v", got, in)
+	}
+}
@@ -0,0 +1,160 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// FileHandler is a Handler that serves the files under a directory, for GET and HEAD requests. See FileServer.
+type FileHandler struct {
+	// ListDirectories renders an HTML listing for directories without an index.html.
+	// If false, those get a 403 Forbidden.
+	ListDirectories bool
+
+	fsys fs.FS
+}
+
+// FileServer returns a handler that serves the files under root, using the request path as the path of the file;
+// e.g, with root "/srv/www", a request for "/css/main.css" gets "/srv/www/css/main.css". Mount it on a Mux with
+// a wildcard, with StripPrefix, if it should serve from somewhere other than "/".
+//
+// Paths are resolved safely: a request path with a ".." segment gets a 400 Bad Request, so nothing outside of
+// root can be reached (except by a symbolic link inside it). A request for a directory gets its index.html;
+// a directory path without a trailing slash is redirected to the one with it, so relative links in the page work.
+//
+// Content-Type comes from the file extension, or, if that's unknown, from sniffing the first 512 bytes of the
+// file (see net/http.DetectContentType). Last-Modified, an ETag and Content-Length come from the file, so clients
+// can revalidate what they have cached and get a 304 Not Modified.
+// Range requests are supported, so downloads can be resumed; see ServeContent.
+func FileServer(root string) *FileHandler {
+	return &FileHandler{fsys: os.DirFS(root)}
+}
+
+func (fh *FileHandler) ServeHTTP(r *Request) *Response {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return errorResponse(405).WithHeader("Allow", "GET, HEAD")
+	}
+	for _, seg := range strings.Split(r.Path, "/") {
+		if seg == ".." {
+			return errorResponse(400)
+		}
+	}
+	name := strings.TrimPrefix(path.Clean("/"+r.Path), "/")
+	if name == "" {
+		name = "."
+	}
+	f, info, err := openFile(fh.fsys, name)
+	if err != nil {
+		return errorResponse(statusForFSError(err))
+	}
+	if !info.IsDir() {
+		return serveFile(r, f, info)
+	}
+	f.Close()
+
+	if !strings.HasSuffix(r.Path, "/") {
+		// relative, so it still works under StripPrefix: "site/" from "/static/site" is "/static/site/".
+		target := (&url.URL{Path: path.Base(r.Path) + "/", RawQuery: r.RawQuery}).String()
+		return errorResponse(301).WithHeader("Location", target)
+	}
+	switch f, info, err := openFile(fh.fsys, path.Join(name, "index.html")); {
+	case err == nil && !info.IsDir():
+		return serveFile(r, f, info)
+	case err == nil:
+		f.Close()
+	}
+	if !fh.ListDirectories {
+		return errorResponse(403)
+	}
+	entries, err := fs.ReadDir(fh.fsys, name)
+	if err != nil {
+		return errorResponse(statusForFSError(err))
+	}
+	return dirListing(r, entries)
+}
+
+// openFile opens the named file, returning its FileInfo along with it.
+func openFile(fsys fs.FS, name string) (fs.File, fs.FileInfo, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, info, nil
+}
+
+// serveFile responds with the contents of f, honoring Range requests; see ServeContent. f is closed when done.
+func serveFile(r *Request, f fs.File, info fs.FileInfo) *Response {
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		f.Close()
+		return errorResponse(500) // can't happen with os.DirFS, whose files are *os.Files.
+	}
+	return ServeContent(r, info.Name(), info.ModTime(), rs)
+}
+
+// dirListing renders an HTML page linking to the entries of a directory, directories first, then by name.
+func dirListing(r *Request, entries []fs.DirEntry) *Response {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].IsDir() != entries[j].IsDir() {
+			return entries[i].IsDir()
+		}
+		return entries[i].Name() < entries[j].Name()
+	})
+	var b strings.Builder
+	title := html.EscapeString(r.Path)
+	fmt.Fprintf(&b, "<!doctype html>\n<meta name=\"viewport\" content=\"width=device-width\">\n<title>%s</title>\n<h1>%s</h1>\n<pre>\n", title, title)
+	if r.Path != "/" {
+		b.WriteString("<a href=\"../\">../</a>\n")
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() {
+			name += "/"
+		}
+		href := (&url.URL{Path: name}).String()
+		if strings.Contains(name, ":") {
+			href = "./" + href // so "a:b" isn't taken for a URL with the scheme "a".
+		}
+		fmt.Fprintf(&b, "<a href=\"%s\">%s</a>\n", html.EscapeString(href), html.EscapeString(name))
+	}
+	b.WriteString("</pre>\n")
+	resp, _ := NewResponse(200, b.String())
+	return resp.WithHeader("Content-Type", "text/html; charset=utf-8")
+}
+
+// statusForFSError picks the status code for an error opening a file.
+func statusForFSError(err error) int {
+	switch {
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, fs.ErrInvalid):
+		return 404
+	case errors.Is(err, fs.ErrPermission):
+		return 403
+	default:
+		return 500
+	}
+}
+
+// errorResponse returns a response with the given status code, and the status text as a plain-text body.
+func errorResponse(status int) *Response {
+	resp, _ := NewResponse(status, "")
+	return resp.WithHeader("Content-Type", "text/plain; charset=utf-8")
+}
+
+// readCloser reads from one thing and closes another; e.g, part of a file, and the file.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,82 @@
+package http
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileServer(t *testing.T) {
+	root := t.TempDir()
+	modTime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	for name, contents := range map[string]string{
+		"hello.txt":          "hello, world",
+		"style.css":          "body { color: red }",
+		"image":              "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR",
+		"notes":              "just some text",
+		"site/index.html":    "<h1>home</h1>",
+		"files/a.txt":        "a",
+		"files/sub/b.txt":    "b",
+		"files/<script>.txt": "c",
+	} {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fs := FileServer(root)
+	listing := FileServer(root)
+	listing.ListDirectories = true
+
+	for name, tt := range map[string]struct {
+		handler      Handler
+		method, path string
+		wantStatus   int
+		wantType     string
+		wantBody     string // for a listing, a substring of it
+		wantHeader   Header // another header that should be set
+	}{
+		"text":            {fs, "GET", "/hello.txt", 200, "text/plain; charset=utf-8", "hello, world", Header{"Last-Modified", "Mon, 06 May 2024 07:08:09 GMT"}},
+		"css":             {fs, "GET", "/style.css", 200, "text/css; charset=utf-8", "body { color: red }", Header{"Content-Length", "19"}},
+		"sniffed image":   {fs, "GET", "/image", 200, "image/png", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", Header{}},
+		"sniffed text":    {fs, "GET", "/notes", 200, "text/plain; charset=utf-8", "just some text", Header{}},
+		"HEAD":            {fs, "HEAD", "/hello.txt", 200, "text/plain; charset=utf-8", "", Header{"Content-Length", "12"}},
+		"index.html":      {fs, "GET", "/site/", 200, "text/html; charset=utf-8", "<h1>home</h1>", Header{}},
+		"add slash":       {fs, "GET", "/site?x=1", 301, "text/plain; charset=utf-8", "Moved Permanently", Header{"Location", "site/?x=1"}},
+		"no listing":      {fs, "GET", "/files/", 403, "text/plain; charset=utf-8", "Forbidden", Header{}},
+		"not found":       {fs, "GET", "/nope.txt", 404, "text/plain; charset=utf-8", "Not Found", Header{}},
+		"dot dot":         {fs, "GET", "/files/../../etc/passwd", 400, "text/plain; charset=utf-8", "Bad Request", Header{}},
+		"POST":            {fs, "POST", "/hello.txt", 405, "text/plain; charset=utf-8", "Method Not Allowed", Header{"Allow", "GET, HEAD"}},
+		"listing":         {listing, "GET", "/files/", 200, "text/html; charset=utf-8", "<a href=\"../\">../</a>\n<a href=\"sub/\">sub/</a>\n<a href=\"%3Cscript%3E.txt\">&lt;script&gt;.txt</a>\n<a href=\"a.txt\">a.txt</a>\n", Header{}},
+		"listing, index":  {listing, "GET", "/site/", 200, "text/html; charset=utf-8", "<h1>home</h1>", Header{}},
+		"under a prefix":  {StripPrefix("/static", fs), "GET", "/static/hello.txt", 200, "text/plain; charset=utf-8", "hello, world", Header{}},
+		"prefix, slash":   {StripPrefix("/static", fs), "GET", "/static/site", 301, "text/plain; charset=utf-8", "Moved Permanently", Header{"Location", "site/"}},
+		"listing of root": {listing, "GET", "/", 200, "text/html; charset=utf-8", "<a href=\"files/\">files/</a>", Header{}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, _ := NewRequest(tt.method, tt.path, "localhost", "")
+			resp := tt.handler.ServeHTTP(req)
+			body := bodyOf(t, resp)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := resp.Headers.Get("Content-Type"); got != tt.wantType {
+				t.Errorf("got Content-Type %q, want %q", got, tt.wantType)
+			}
+			if strings.HasPrefix(name, "listing") && !strings.Contains(body, tt.wantBody) || !strings.HasPrefix(name, "listing") && body != tt.wantBody {
+				t.Errorf("got body %q, want %q", body, tt.wantBody)
+			}
+			if tt.wantHeader.Key != "" && resp.Headers.Get(tt.wantHeader.Key) != tt.wantHeader.Value {
+				t.Errorf("got %s %q, want %q", tt.wantHeader.Key, resp.Headers.Get(tt.wantHeader.Key), tt.wantHeader.Value)
+			}
+		})
+	}
+}
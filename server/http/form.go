@@ -0,0 +1,167 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"sync"
+)
+
+// Forms come in two encodings (HTML 4.01 section 17.13.4):
+//
+//	application/x-www-form-urlencoded   name=Gopher&lang=go, like a query string; small, text-only forms
+//	multipart/form-data                 one MIME part per field, each with its own headers; needed for file uploads
+//
+// ParseForm handles the first, ParseMultipartForm and MultipartReader the second.
+
+// DefaultMaxMemory is how much of a multipart form FormValue keeps in memory; file parts past that go to temporary files.
+const DefaultMaxMemory = 32 << 20
+
+// ErrNotMultipart is returned by MultipartReader and ParseMultipartForm if the body isn't multipart/form-data.
+var ErrNotMultipart = errors.New("http: request Content-Type isn't multipart/form-data")
+
+// ParseForm fills in r.Form and r.PostForm. PostForm gets the fields of an application/x-www-form-urlencoded body,
+// for POST, PUT and PATCH requests; Form gets those, followed by the query parameters. Other bodies are left alone.
+// The body is read into memory, so it's limited by the server's Parser.MaxBodyBytes; it can still be read afterwards.
+// ParseForm does nothing after the first time.
+func (r *Request) ParseForm() error {
+	if r.PostForm != nil {
+		return nil
+	}
+	var err error
+	r.PostForm = make(url.Values)
+	if r.Body != nil && hasFormBody(r.Method) && mediaType(r.Headers) == "application/x-www-form-urlencoded" {
+		var b []byte
+		if b, err = r.BodyBytes(); err == nil {
+			r.PostForm, err = url.ParseQuery(string(b))
+		}
+		if err != nil {
+			err = fmt.Errorf("http: parsing form: %w", err)
+		}
+	}
+	if r.Form == nil {
+		r.Form = make(url.Values)
+		for k, v := range r.PostForm {
+			r.Form[k] = append(r.Form[k], v...)
+		}
+		query, qerr := url.ParseQuery(r.RawQuery)
+		for k, v := range query {
+			r.Form[k] = append(r.Form[k], v...)
+		}
+		if err == nil && qerr != nil {
+			err = fmt.Errorf("http: parsing query: %w", qerr)
+		}
+	}
+	return err
+}
+
+// ParseMultipartForm parses a multipart/form-data body into r.MultipartForm, adding its (non-file) fields to
+// r.Form and r.PostForm, too. Up to maxMemory bytes of file parts are kept in memory; the rest spill over to
+// temporary files. On the server, those are removed once the response is sent, even if it was a copy of the
+// request that was parsed, e.g by a handler behind the Timeout middleware; elsewhere, call r.MultipartForm.RemoveAll
+// when done. It calls ParseForm first, and does nothing after the first time.
+func (r *Request) ParseMultipartForm(maxMemory int64) error {
+	if r.MultipartForm != nil {
+		return nil
+	}
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return err
+	}
+	form, err := mr.ReadForm(maxMemory)
+	if err != nil {
+		return fmt.Errorf("http: parsing multipart form: %w", err)
+	}
+	for k, v := range form.Value {
+		r.Form[k] = append(append([]string(nil), v...), r.Form[k]...) // the body's fields come first, as for ParseForm.
+		r.PostForm[k] = append(r.PostForm[k], v...)
+	}
+	r.MultipartForm = form
+	r.forms.add(form)
+	return nil
+}
+
+// formFiles keeps track of the multipart forms parsed from a request, so their temporary files can be removed
+// once the response is sent. A handler might parse the form of a copy of the request, e.g the one WithContext
+// returns, which has a MultipartForm of its own; since copies share the formFiles, its files are removed all the same.
+type formFiles struct {
+	mu    sync.Mutex
+	forms []*multipart.Form
+	done  bool // removeAll has been called: any later forms are removed straight away.
+}
+
+// add keeps track of form, or removes it at once if it's too late. A nil *formFiles keeps track of nothing:
+// the forms of a request that isn't from a server are the caller's to remove.
+func (ff *formFiles) add(form *multipart.Form) {
+	if ff == nil {
+		return
+	}
+	ff.mu.Lock()
+	done := ff.done
+	if !done {
+		ff.forms = append(ff.forms, form)
+	}
+	ff.mu.Unlock()
+	if done {
+		form.RemoveAll() // parsed by a handler that carried on after the response was sent, e.g one Timeout gave up on.
+	}
+}
+
+// removeAll removes the temporary files of the forms kept track of, and of any added later.
+func (ff *formFiles) removeAll() {
+	if ff == nil {
+		return
+	}
+	ff.mu.Lock()
+	forms := ff.forms
+	ff.forms, ff.done = nil, true
+	ff.mu.Unlock()
+	for _, form := range forms {
+		form.RemoveAll()
+	}
+}
+
+// MultipartReader returns a reader for the parts of a multipart/form-data body, to stream them one at a time,
+// rather than have ParseMultipartForm read them all up front. Each part has its own headers (Content-Disposition
+// gives the field name and, for files, the file name), and its body is read straight from the request's.
+func (r *Request) MultipartReader() (*multipart.Reader, error) {
+	if r.MultipartForm != nil {
+		return nil, errors.New("http: multipart body already read by ParseMultipartForm")
+	}
+	if r.Body == nil {
+		return nil, errors.New("http: multipart form has no body")
+	}
+	mt, params, err := mime.ParseMediaType(r.Headers.Get("Content-Type"))
+	if err != nil || mt != "multipart/form-data" {
+		return nil, ErrNotMultipart
+	}
+	if params["boundary"] == "" {
+		return nil, errors.New("http: multipart form has no boundary")
+	}
+	return multipart.NewReader(r.Body, params["boundary"]), nil
+}
+
+// FormValue returns the first value of the named form field, from the body or the query, parsing them if need be.
+// It returns "" if there's no such field; errors are ignored. Use ParseForm or ParseMultipartForm to see them.
+func (r *Request) FormValue(key string) string {
+	if r.MultipartForm == nil && mediaType(r.Headers) == "multipart/form-data" {
+		r.ParseMultipartForm(DefaultMaxMemory)
+	}
+	r.ParseForm()
+	return r.Form.Get(key)
+}
+
+// hasFormBody reports whether form fields are read from the body of a request with the given method.
+func hasFormBody(method string) bool { return method == "POST" || method == "PUT" || method == "PATCH" }
+
+// mediaType returns the media type of the Content-Type header, lowercased and without parameters,
+// e.g "text/html" for "Text/HTML; charset=utf-8".
+func mediaType(h Headers) string {
+	mt, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return mt
+}
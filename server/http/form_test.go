@@ -0,0 +1,207 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseForm(t *testing.T) {
+	for name, tt := range map[string]struct {
+		method, target, contentType, body string
+		wantForm, wantPostForm            url.Values
+		wantErr                           bool
+	}{
+		"query only": {
+			method: "GET", target: "/?a=1&b=2",
+			wantForm: url.Values{"a": {"1"}, "b": {"2"}}, wantPostForm: url.Values{},
+		},
+		"urlencoded body": {
+			method: "POST", target: "/?a=query", contentType: "application/x-www-form-urlencoded", body: "a=body&c=x+y%21",
+			wantForm: url.Values{"a": {"body", "query"}, "c": {"x y!"}}, wantPostForm: url.Values{"a": {"body"}, "c": {"x y!"}},
+		},
+		"charset parameter": {
+			method: "PUT", target: "/", contentType: "application/x-www-form-urlencoded; charset=utf-8", body: "a=1",
+			wantForm: url.Values{"a": {"1"}}, wantPostForm: url.Values{"a": {"1"}},
+		},
+		"not a form": {
+			method: "POST", target: "/", contentType: "application/json", body: `{"a":1}`,
+			wantForm: url.Values{}, wantPostForm: url.Values{},
+		},
+		"body ignored for GET": {
+			method: "GET", target: "/", contentType: "application/x-www-form-urlencoded", body: "a=1",
+			wantForm: url.Values{}, wantPostForm: url.Values{},
+		},
+		"malformed body": {
+			method: "POST", target: "/", contentType: "application/x-www-form-urlencoded", body: "a=%zz",
+			wantForm: url.Values{}, wantPostForm: url.Values{}, wantErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, _ := NewRequest(tt.method, tt.target, "localhost", tt.body)
+			if tt.contentType != "" {
+				req.Headers.Set("Content-Type", tt.contentType)
+			}
+			if err := req.ParseForm(); (err != nil) != tt.wantErr {
+				t.Errorf("ParseForm: got error %v, want error: %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(req.Form, tt.wantForm) {
+				t.Errorf("Form is %v, want %v", req.Form, tt.wantForm)
+			}
+			if !reflect.DeepEqual(req.PostForm, tt.wantPostForm) {
+				t.Errorf("PostForm is %v, want %v", req.PostForm, tt.wantPostForm)
+			}
+			if body, _ := req.BodyBytes(); string(body) != tt.body {
+				t.Errorf("body after ParseForm is %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+// multipartRequest builds a multipart/form-data POST with the given fields, and a file "upload" with the given contents.
+func multipartRequest(t *testing.T, fields map[string]string, file string) *Request {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	fw, err := mw.CreateFormFile("upload", "hello.txt")
+	if err != nil {
+		t.Fatalf("CreateFormFile: %v", err)
+	}
+	fw.Write([]byte(file))
+	mw.Close()
+	req, _ := NewRequest("POST", "/upload?q=1", "localhost", buf.String())
+	req.Headers.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestParseMultipartForm(t *testing.T) {
+	for name, maxMemory := range map[string]int64{"in memory": 1 << 20, "spilled to disk": 1} {
+		t.Run(name, func(t *testing.T) {
+			file := string(bytes.Repeat([]byte("file contents "), 100))
+			req := multipartRequest(t, map[string]string{"name": "gopher"}, file)
+			if err := req.ParseMultipartForm(maxMemory); err != nil {
+				t.Fatalf("ParseMultipartForm: %v", err)
+			}
+			defer req.MultipartForm.RemoveAll()
+
+			if got := req.FormValue("name"); got != "gopher" {
+				t.Errorf(`FormValue("name") = %q, want "gopher"`, got)
+			}
+			if got := req.FormValue("q"); got != "1" {
+				t.Errorf(`FormValue("q") = %q, want "1"`, got)
+			}
+			if got := req.PostForm.Get("q"); got != "" {
+				t.Errorf(`PostForm.Get("q") = %q, want ""`, got)
+			}
+			fhs := req.MultipartForm.File["upload"]
+			if len(fhs) != 1 || fhs[0].Filename != "hello.txt" {
+				t.Fatalf("got files %+v, want hello.txt", fhs)
+			}
+			f, err := fhs[0].Open()
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			defer f.Close()
+			if _, onDisk := f.(*os.File); onDisk != (maxMemory == 1) {
+				t.Errorf("file on disk: %v, want %v", onDisk, maxMemory == 1)
+			}
+			if b, _ := io.ReadAll(f); string(b) != file {
+				t.Errorf("got file %q, want %q", b, file)
+			}
+		})
+	}
+}
+
+func TestMultipartReader(t *testing.T) {
+	req := multipartRequest(t, map[string]string{"name": "gopher"}, "file contents")
+	mr, err := req.MultipartReader()
+	if err != nil {
+		t.Fatalf("MultipartReader: %v", err)
+	}
+	var got []string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		b, _ := io.ReadAll(part)
+		got = append(got, part.FormName()+"|"+part.FileName()+"|"+part.Header.Get("Content-Type")+"|"+string(b))
+	}
+	want := []string{"name|||gopher", "upload|hello.txt|application/octet-stream|file contents"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got parts %q, want %q", got, want)
+	}
+
+	notMultipart, _ := NewRequest("POST", "/", "localhost", "a=1")
+	notMultipart.Headers.Set("Content-Type", "application/x-www-form-urlencoded")
+	if _, err := notMultipart.MultipartReader(); err != ErrNotMultipart {
+		t.Errorf("MultipartReader on a urlencoded body: got %v, want %v", err, ErrNotMultipart)
+	}
+}
+
+func TestMultipartFormCleanup(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp) // where the spilled file parts go.
+	spilled := func() int {
+		files, _ := os.ReadDir(tmp)
+		return len(files)
+	}
+	parse := HandlerFunc(func(r *Request) *Response {
+		if err := r.ParseMultipartForm(1); err != nil {
+			t.Errorf("ParseMultipartForm: %v", err)
+		}
+		resp, _ := NewResponse(200, fmt.Sprintf("%d spilled", spilled()))
+		return resp
+	})
+	stdParse := http.HandlerFunc(func(w http.ResponseWriter, sr *http.Request) {
+		if err := sr.ParseMultipartForm(1); err != nil {
+			t.Errorf("ParseMultipartForm: %v", err)
+		}
+		fmt.Fprintf(w, "%d spilled", spilled())
+	})
+	serve := func(h Handler) func(*Request) *Response {
+		return func(req *Request) *Response {
+			addr := startServer(t, &Server{Handler: h})
+			var raw bytes.Buffer
+			req.Headers.Set("Connection", "close")
+			req.WriteTo(&raw)
+			return roundTrip(t, addr, raw.String())
+		}
+	}
+	for name, do := range map[string]func(*Request) *Response{
+		"server":         serve(parse),
+		"Timeout":        serve(Timeout(time.Minute)(parse)), // which parses a copy of the request.
+		"FromStdHandler": serve(FromStdHandler(stdParse)),
+		"ToStdHandler": func(req *Request) *Response {
+			sr, _ := ToStdRequest(req)
+			rec := httptest.NewRecorder()
+			ToStdHandler(Timeout(time.Minute)(parse)).ServeHTTP(rec, sr)
+			resp, _ := FromStdResponse(rec.Result())
+			return resp
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := multipartRequest(t, nil, string(bytes.Repeat([]byte("file contents "), 100)))
+			if got := bodyOf(t, do(req)); got != "1 spilled" {
+				t.Fatalf("got %q from the handler, want %q", got, "1 spilled")
+			}
+			if n := spilled(); n != 0 {
+				t.Errorf("%d temporary files left behind", n)
+			}
+		})
+	}
+}
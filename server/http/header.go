@@ -1,15 +1,136 @@
 package http
 
-import "strings"
+import (
+	"strings"
+	"time"
+)
 
 // Header represents an HTTP header. An HTTP header is a key-value pair, separated by a colon(:);
 // The ky should be formatted in Title-Case.
-// Use Request.AddHeader() or Response.AddHeader() to add headers to a request or response
+// Use Headers.Add() or the WithHeader() builders on Request and Response to add headers to a request or response
 // and guarantee title-casing of the key.
 type Header struct {
 	Key, Value string
 }
 
+// Headers is an ordered list of headers. A key can show up more than once, e.g. for Set-Cookie;
+// the order is kept, since it matters for those repeated keys, and so messages round-trip exactly.
+// Every method canonicalizes the key with AsTitle, so lookups are case-insensitive.
+// Messages rarely have more than a dozen headers, so a slice is faster than a map here.
+type Headers []Header
+
+// Get returns the value of the first header with the given key, or "" if there's none.
+func (h Headers) Get(key string) string {
+	key = AsTitle(key)
+	for i := range h {
+		if h[i].Key == key {
+			return h[i].Value
+		}
+	}
+	return ""
+}
+
+// Values returns the values of every header with the given key, in order.
+func (h Headers) Values(key string) []string {
+	key = AsTitle(key)
+	var vals []string
+	for i := range h {
+		if h[i].Key == key {
+			vals = append(vals, h[i].Value)
+		}
+	}
+	return vals
+}
+
+// Has returns true if there's at least one header with the given key, even if its value is empty.
+func (h Headers) Has(key string) bool {
+	key = AsTitle(key)
+	for i := range h {
+		if h[i].Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Add appends a header, keeping any existing headers with the same key.
+func (h *Headers) Add(key, value string) {
+	*h = append(*h, Header{AsTitle(key), value})
+}
+
+// Set replaces every header with the given key by a single one with the given value.
+// The new header takes the place of the first existing one, or is appended if there were none.
+func (h *Headers) Set(key, value string) {
+	key = AsTitle(key)
+	for i := range *h {
+		if (*h)[i].Key == key {
+			(*h)[i].Value = value
+			*h = append((*h)[:i+1], (*h)[i+1:].without(key)...)
+			return
+		}
+	}
+	*h = append(*h, Header{key, value})
+}
+
+// Del removes every header with the given key.
+func (h *Headers) Del(key string) {
+	*h = h.without(AsTitle(key))
+}
+
+// without filters out headers with the (already canonical) key in place.
+func (h Headers) without(key string) Headers {
+	kept := h[:0]
+	for i := range h {
+		if h[i].Key != key {
+			kept = append(kept, h[i])
+		}
+	}
+	return kept
+}
+
+// Clone returns a copy of h that can be modified without affecting the original.
+func (h Headers) Clone() Headers {
+	if h == nil {
+		return nil
+	}
+	return append(make(Headers, 0, len(h)), h...)
+}
+
+// hasToken returns true if any of the comma-separated values of the given header contain token, ignoring case.
+// e.g, hasToken(h, "Connection", "close") is true for "Connection: Upgrade, Close".
+func hasToken(h Headers, key, token string) bool {
+	for _, v := range h.Values(key) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TimeFormat is the format for dates in header fields, e.g Expires or Last-Modified: the IMF-fixdate of
+// RFC 9110 section 5.6.7, e.g "Sun, 06 Nov 1994 08:49:37 GMT". The time must be in UTC.
+const TimeFormat = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+// timeFormats are the date formats we accept: the IMF-fixdate we send, plus the two obsolete ones
+// RFC 9110 says recipients should still understand.
+var timeFormats = []string{
+	TimeFormat,
+	"Monday, 02-Jan-06 15:04:05 GMT", // RFC 850
+	time.ANSIC,                       // asctime
+}
+
+// parseTime parses a date from a header field, in any of the formats of timeFormats.
+func parseTime(s string) (time.Time, bool) {
+	for _, layout := range timeFormats {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
 // AsTitle returns the given header key as title case; e.g. "content-type" -> "Content-Type"
 // You can implement this to use the standard library in Go
 // see https://pkg.go.dev/net/textproto#CanonicalMIMEHeaderKey
@@ -1,6 +1,10 @@
 package http
 
 import (
+	"bufio"
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -17,3 +21,92 @@ func TestTitleCaseKey(t *testing.T) {
 		}
 	}
 }
+
+func TestHeaders(t *testing.T) {
+	var h Headers
+	h.Add("content-type", "text/plain")
+	h.Add("Set-Cookie", "a=1")
+	h.Add("SET-COOKIE", "b=2")
+	h.Add("x-request-id", "42")
+
+	if got := h.Get("CONTENT-TYPE"); got != "text/plain" {
+		t.Errorf("Get(%q) = %q, want %q", "CONTENT-TYPE", got, "text/plain")
+	}
+	if got := h.Get("missing"); got != "" {
+		t.Errorf("Get(%q) = %q, want \"\"", "missing", got)
+	}
+	if got, want := h.Values("set-cookie"), []string{"a=1", "b=2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Values(%q) = %q, want %q", "set-cookie", got, want)
+	}
+
+	clone := h.Clone()
+	h.Set("Set-Cookie", "c=3") // replaces both cookies, in the place of the first one.
+	if want := (Headers{{"Content-Type", "text/plain"}, {"Set-Cookie", "c=3"}, {"X-Request-Id", "42"}}); !reflect.DeepEqual(h, want) {
+		t.Errorf("after Set, headers = %v, want %v", h, want)
+	}
+	h.Set("Accept", "*/*")
+	h.Del("content-type")
+	if want := (Headers{{"Set-Cookie", "c=3"}, {"X-Request-Id", "42"}, {"Accept", "*/*"}}); !reflect.DeepEqual(h, want) {
+		t.Errorf("after Set & Del, headers = %v, want %v", h, want)
+	}
+	// the clone shouldn't see any of that.
+	if want := (Headers{{"Content-Type", "text/plain"}, {"Set-Cookie", "a=1"}, {"Set-Cookie", "b=2"}, {"X-Request-Id", "42"}}); !reflect.DeepEqual(clone, want) {
+		t.Errorf("clone = %v, want %v", clone, want)
+	}
+}
+
+func TestReadHeaderFields(t *testing.T) {
+	for name, tt := range map[string]struct {
+		fields string // header lines, each terminated by CRLF
+		strict bool
+		want   Headers
+	}{
+		"no space":     {fields: "Key:value\r\n", want: Headers{{"Key", "value"}}},
+		"extra OWS":    {fields: "Key: \t value \t\r\n", want: Headers{{"Key", "value"}}},
+		"empty value":  {fields: "Key:\r\n", want: Headers{{"Key", ""}}},
+		"colon in val": {fields: "Location: http://example.com/\r\n", want: Headers{{"Location", "http://example.com/"}}},
+		"obs-fold": {
+			fields: "Key: first\r\n  second\r\n\tthird\r\nOther: x\r\n",
+			want:   Headers{{"Key", "first second third"}, {"Other", "x"}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			raw := "GET / HTTP/1.1\r\nHost: a\r\n" + tt.fields + "\r\n"
+			got, err := (&Parser{Strict: tt.strict}).ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+			if err != nil {
+				t.Fatalf("ReadRequest(%q) returned error: %v", raw, err)
+			}
+			if want := append(Headers{{"Host", "a"}}, tt.want...); !reflect.DeepEqual(got.Headers, want) {
+				t.Errorf("ReadRequest(%q).Headers = %v, want %v", raw, got.Headers, want)
+			}
+		})
+	}
+}
+
+func TestReadHeaderFieldErrors(t *testing.T) {
+	for name, tt := range map[string]struct {
+		fields   string
+		strict   bool
+		wantKind error
+	}{
+		"space before colon": {fields: "Key : value\r\n", wantKind: ErrBadHeader},
+		"space in name":      {fields: "Bad Key: value\r\n", wantKind: ErrBadHeader},
+		"bad name char":      {fields: "Key(1): value\r\n", wantKind: ErrBadHeader},
+		"control char":       {fields: "Key: a\x00b\r\n", wantKind: ErrBadHeader},
+		"bare CR":            {fields: "Key: a\rb\r\n", wantKind: ErrBadHeader},
+		"obs-fold (strict)":  {fields: "Key: first\r\n second\r\n", strict: true, wantKind: ErrObsFold},
+	} {
+		t.Run(name, func(t *testing.T) {
+			raw := "GET / HTTP/1.1\r\nHost: a\r\n" + tt.fields + "\r\n"
+			_, err := (&Parser{Strict: tt.strict}).ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+			if !errors.Is(err, tt.wantKind) {
+				t.Errorf("ReadRequest(%q) returned error %v, want %v", raw, err, tt.wantKind)
+			}
+		})
+	}
+	// a fold can't be the first header line, even in lenient mode: there's nothing to continue.
+	raw := "GET / HTTP/1.1\r\n Host: a\r\n\r\n"
+	if _, err := ReadRequest(bufio.NewReader(strings.NewReader(raw))); !errors.Is(err, ErrObsFold) {
+		t.Errorf("ReadRequest(%q) returned error %v, want %v", raw, err, ErrObsFold)
+	}
+}
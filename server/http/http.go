@@ -1,11 +1,12 @@
 package http
 
 import (
+	"bufio"
 	"bytes"
 	"encoding"
+	"errors"
 	"fmt"
-	"log"
-	"net/http"
+	"io"
 	"strconv"
 	"strings"
 )
@@ -30,113 +31,353 @@ func (resp *Response) MarshalText() ([]byte, error) {
 // Interface Section -- End
 
 // ParseRequest parses a HTTP request from the given text.
+// It's a convenience wrapper around ReadRequest for when the whole request is already in memory;
+// the body is read into memory, too.
 func ParseRequest(raw string) (r Request, err error) {
+	req, err := ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+	if err != nil {
+		return Request{}, err
+	}
+	if _, err := req.BodyBytes(); err != nil {
+		return Request{}, err
+	}
+	return *req, nil
+}
+
+// ParseResponse parses the given HTTP/1.1 response string into the Response. It returns an error if the Response is invalid,
+// - not a valid integer
+// - invalid status code
+// - invalid headers
+// It's a convenience wrapper around ReadResponse for when the whole response is already in memory;
+// the body is read into memory, too.
+func ParseResponse(raw string) (resp *Response, err error) {
+	resp, err = ReadResponse(bufio.NewReader(strings.NewReader(raw)))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := resp.BodyBytes(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ReadRequest reads and parses a single HTTP request from br, using the default limits. See Parser.ReadRequest.
+func ReadRequest(br *bufio.Reader) (*Request, error) { return new(Parser).ReadRequest(br) }
+
+// ReadResponse reads and parses a single HTTP response from br, using the default limits. See Parser.ReadResponse.
+func ReadResponse(br *bufio.Reader) (*Response, error) { return new(Parser).ReadResponse(br) }
+
+// ReadRequest reads and parses a single HTTP request from br.
+// The request line and headers are read one line at a time. The body isn't read at all: it streams from br as
+// Request.Body is read, and stops where the Content-Length or chunked encoding says it ends. So ReadRequest never
+// consumes more than the request itself: once the body is read, call it again on the same reader to get the next request.
+// It returns io.EOF if br is exhausted before the request line starts. Malformed requests get a *ParseError.
+func (p *Parser) ReadRequest(br *bufio.Reader) (*Request, error) {
 	// request has three parts:
-	// 1. Request linedd
+	// 1. Request line
 	// 2. Headers
 	// 3. Body (optional)
-	lines := splitLines(raw)
+	mr := &msgReader{br: br}
+	line, err := mr.readStartLine(p.maxRequestLineBytes())
+	if err != nil {
+		return nil, err
+	}
+	// First line is special: GET / HTTP/1.1
+	first := strings.Fields(line)
+	if len(first) != 3 || !strings.HasPrefix(first[2], "HTTP/") {
+		return nil, mr.errorf(ErrBadRequestLine, line, "should be of form 'METHOD PATH HTTP/VERSION'")
+	}
+	r := new(Request)
+	r.Method = first[0]
+	if err := r.parseTarget(first[1]); err != nil {
+		return nil, mr.errorf(ErrBadTarget, first[1], "%v", err)
+	}
+	if r.Proto = first[2]; !validProto(r.Proto) {
+		return nil, mr.errorf(ErrUnsupportedVersion, r.Proto, "should be HTTP/1.0 or HTTP/1.1")
+	}
+	if r.Headers, err = p.readHeaders(mr); err != nil {
+		return nil, err
+	}
+	if !r.Headers.Has("Host") { // special case: host header is required.
+		return nil, mr.errorf(ErrMissingHost, "", "")
+	}
+	// RFC 9112 section 6.3: a request with a Transfer-Encoding we can't frame is an error, since we can't tell where it ends.
+	if r.Headers.Has("Transfer-Encoding") && !isChunked(r.Headers) {
+		return nil, mr.errorf(ErrBadTransferEncoding, r.Headers.Get("Transfer-Encoding"), "final transfer coding should be chunked")
+	}
+	// a request without a Content-Length has no body; we can't wait for EOF, since the client is waiting for us.
+	if r.Body, r.ContentLength, err = p.readBody(mr, r.Headers, false, p.maxBodyBytes(), &r.Trailer); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ReadResponse reads and parses a single HTTP response from br.
+// Like ReadRequest, the body streams from br; if there's neither a Content-Length nor chunked encoding, it runs until EOF.
+// Interim (1xx) responses before it, e.g 100 Continue, are skipped, except for 101 Switching Protocols, after which
+// there's no HTTP response to come. Malformed responses get a *ParseError.
+func (p *Parser) ReadResponse(br *bufio.Reader) (*Response, error) { return p.readResponse(br, nil) }
 
-	log.Println(lines)
-	if len(lines) < 3 {
-		return Request{}, fmt.Errorf("malformed request: should have at least 3 lines")
+// maxInterimResponses is the most interim responses readResponse skips before giving up on the final one.
+const maxInterimResponses = 5
+
+// errTooManyInterim is returned by readResponse if the server keeps sending interim responses.
+var errTooManyInterim = fmt.Errorf("more than %d interim (1xx) responses", maxInterimResponses)
+
+// readResponse reads the final response to req, skipping interim ones; see ReadResponse.
+func (p *Parser) readResponse(br *bufio.Reader, req *Request) (*Response, error) {
+	for i := 0; i <= maxInterimResponses; i++ {
+		resp, err := p.readOneResponse(br, req)
+		if err != nil || resp.StatusCode >= 200 || resp.StatusCode == 101 {
+			return resp, err
+		}
 	}
-	// First line is special.
-	first := strings.Fields(lines[0])
-	r.Method, r.Path = first[0], first[1]
-	if !strings.HasPrefix(r.Path, "/") {
-		return Request{}, fmt.Errorf("malformed request: path should start with /")
+	return nil, errTooManyInterim
+}
+
+// readOneResponse reads the response to req, which may be nil if we don't know what it was, interim or not.
+// Knowing matters, since the response to a HEAD request never has a body, whatever its headers say.
+func (p *Parser) readOneResponse(br *bufio.Reader, req *Request) (*Response, error) {
+	mr := &msgReader{br: br}
+	line, err := mr.readStartLine(p.maxRequestLineBytes())
+	if err != nil {
+		return nil, err
 	}
-	if !strings.Contains(first[2], "HTTP") {
-		return Request{}, fmt.Errorf("malformed request: first line should contain HTTP version")
+	// First line is special: HTTP/1.1 200 OK
+	// the status text is optional, and we ignore it anyways; the status code is what matters.
+	first := strings.SplitN(line, " ", 3)
+	if len(first) < 2 || !strings.HasPrefix(first[0], "HTTP/") {
+		return nil, mr.errorf(ErrBadStatusLine, line, "should be of form 'HTTP/VERSION CODE TEXT'")
 	}
-	var foundhost bool
-	var bodyStart int
-	// then we have headers, up until the an empty line.
-	for i := 1; i < len(lines); i++ {
-		if lines[i] == "" { // empty line
-			bodyStart = i + 1
-			break
+	if !validProto(first[0]) {
+		return nil, mr.errorf(ErrUnsupportedVersion, first[0], "should be HTTP/1.0 or HTTP/1.1")
+	}
+	resp := &Response{Proto: first[0]}
+	resp.StatusCode, err = strconv.Atoi(first[1])
+	if err != nil || len(first[1]) != 3 || resp.StatusCode < 100 || resp.StatusCode > 599 {
+		return nil, mr.errorf(ErrBadStatusLine, first[1], "status code should be a 3-digit integer from 100 to 599")
+	}
+	if resp.Headers, err = p.readHeaders(mr); err != nil {
+		return nil, err
+	}
+	resp.Request = req
+	if !resp.hasBody() {
+		return resp, nil
+	}
+	if resp.Body, resp.ContentLength, err = p.readBody(mr, resp.Headers, true, -1, &resp.Trailer); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// validProto returns true for the HTTP versions we speak: HTTP/1.0 and HTTP/1.1.
+func validProto(proto string) bool { return proto == "HTTP/1.0" || proto == "HTTP/1.1" }
+
+// readHeaders reads header lines up until (and including) the empty line that ends them.
+func (p *Parser) readHeaders(mr *msgReader) (Headers, error) {
+	var headers Headers
+	budget, maxCount := p.maxHeaderBytes(), p.maxHeaderCount()
+	for {
+		line, err := mr.readLine(budget)
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF // we got a start line, so the headers must be there.
 		}
-		key, val, ok := strings.Cut(lines[i], ": ")
-		if !ok {
-			return Request{}, fmt.Errorf("malformed request: header %q should be of form 'key: value'", lines[i])
+		if err == errLineTooLong {
+			return nil, mr.errorf(ErrHeaderTooLarge, "", "header section is limited to %d bytes", p.maxHeaderBytes())
 		}
-		if key == "Host" { // special case: host header is required.
-			foundhost = true
+		if err != nil {
+			return nil, err
 		}
-		key = AsTitle(key)
+		if budget >= 0 {
+			budget -= len(line) + len("\r\n")
+		}
+		if line == "" { // empty line
+			return headers, nil
+		}
+		if exceeds(len(headers)+1, maxCount) {
+			return nil, mr.errorf(ErrTooManyHeaders, line, "header section is limited to %d fields", maxCount)
+		}
+		if line[0] == ' ' || line[0] == '\t' { // obs-fold: a continuation of the previous line's value.
+			if p.Strict || len(headers) == 0 {
+				return nil, mr.errorf(ErrObsFold, line, "obsolete line folding isn't allowed")
+			}
+			// RFC 9112 section 5.2: replace the fold with a single space.
+			val := trimOWS(line)
+			if !validFieldValue(val) {
+				return nil, mr.errorf(ErrBadHeader, line, "field value contains control characters")
+			}
+			if last := &headers[len(headers)-1]; last.Value == "" {
+				last.Value = val
+			} else if val != "" {
+				last.Value += " " + val
+			}
+			continue
+		}
+		key, val, err := parseHeaderLine(line)
+		if err != nil {
+			return nil, mr.errorf(ErrBadHeader, line, "%v", err)
+		}
+		headers.Add(key, val)
+	}
+}
 
-		r.Headers = append(r.Headers, Header{key, val})
+// parseHeaderLine splits a header line into its name and value, as in RFC 9110 section 5 and RFC 9112 section 5:
+//
+//	field-line = field-name ":" OWS field-value OWS
+//
+// The name has to be a token, with no whitespace before the colon; the whitespace around the value is trimmed.
+func parseHeaderLine(line string) (key, val string, err error) {
+	key, val, ok := strings.Cut(line, ":")
+	switch {
+	case !ok:
+		return "", "", errors.New("should be of form 'key: value'")
+	case key == "":
+		return "", "", errors.New("missing field name")
+	case strings.TrimRight(key, " \t") != key:
+		// RFC 9112 section 5.1 says we MUST reject these: they've been used for request smuggling.
+		return "", "", errors.New("no whitespace is allowed between the field name and colon")
+	case !isToken(key):
+		return "", "", fmt.Errorf("field name %q should be a token", key)
 	}
-	end := len(lines) - 1 // recombine the body using normal newlines; skip the last empty line.
-	r.Body = strings.Join(lines[bodyStart:end], "\r\n")
-	if !foundhost {
-		return Request{}, fmt.Errorf("malformed request: missing Host header")
+	val = trimOWS(val)
+	if !validFieldValue(val) {
+		return "", "", errors.New("field value contains control characters")
 	}
-	return r, nil
+	return key, val, nil
 }
 
-// ParseResponse parses the given HTTP/1.1 response string into the Response. It returns an error if the Response is invalid,
-// - not a valid integer
-// - invalid status code
-// - missing status text
-// - invalid headers
-// it doesn't properly handle multi-line headers, headers with multiple values, or html-encoding, etc.zzs
-func ParseResponse(raw string) (resp *Response, err error) {
-	// response has three parts:
-	// 1. Response line
-	// 2. Headers
-	// 3. Body (optional)
-	lines := splitLines(raw)
-	log.Println(lines)
+// trimOWS trims optional whitespace (spaces and tabs) from both ends of s.
+func trimOWS(s string) string { return strings.Trim(s, " \t") }
 
-	// First line is special.
-	first := strings.SplitN(lines[0], " ", 3)
-	if !strings.Contains(first[0], "HTTP") {
-		return nil, fmt.Errorf("malformed response: first line should contain HTTP version")
-	}
-	resp = new(Response)
-	resp.StatusCode, err = strconv.Atoi(first[1])
-	if err != nil {
-		return nil, fmt.Errorf("malformed response: expected status code to be an integer, got %q", first[1])
+// validFieldValue returns false if s contains control characters other than tab; in particular, a bare CR or NUL.
+func validFieldValue(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < ' ' && c != '\t' || c == 0x7f {
+			return false
+		}
 	}
-	if first[2] == "" || http.StatusText(resp.StatusCode) != first[2] {
-		log.Printf("missing or incorrect status text for status code %d: expected %q, but got %q", resp.StatusCode, http.StatusText(resp.StatusCode), first[2])
+	return true
+}
+
+// readBody sets up the body framed by the Transfer-Encoding or Content-Length header, in that order of preference,
+// returning it with its length, or -1 if the length isn't known up front.
+// Bodies longer than max bytes fail with ErrBodyTooLarge. If the body is chunked, the trailer is stored in *trailer
+// once the body's been read to the end.
+// If there's neither header and untilEOF is set, the body is everything up until EOF; otherwise, there's no body.
+func (p *Parser) readBody(mr *msgReader, headers Headers, untilEOF bool, max int64, trailer *Headers) (io.ReadCloser, int64, error) {
+	switch {
+	case isChunked(headers):
+		cr := &ChunkedReader{mr: mr, limits: *p}
+		return &body{src: cr, remaining: -1, max: max, mr: mr, onEOF: func() { *trailer = cr.Trailer() }}, -1, nil
+	case headers.Has("Content-Length") && !headers.Has("Transfer-Encoding"): // Transfer-Encoding overrides Content-Length.
+		n, err := contentLength(headers)
+		if err != nil {
+			return nil, 0, mr.errorf(ErrBadContentLength, strings.Join(headers.Values("Content-Length"), ", "), "%v", err)
+		}
+		if exceeds(n, max) { // don't even bother reading it.
+			return nil, 0, mr.bodyErrorf(ErrBodyTooLarge, "body is limited to %d bytes, but Content-Length is %d", max, n)
+		}
+		if n == 0 {
+			return nil, 0, nil
+		}
+		return &body{src: mr, remaining: n, max: -1, mr: mr}, n, nil
+	case untilEOF:
+		return &body{src: mr, remaining: -1, max: max, mr: mr}, -1, nil
+	default:
+		return nil, 0, nil
 	}
-	var bodyStart int
-	// then we have headers, up until the an empty line.
-	for i := 1; i < len(lines); i++ {
-		log.Println(i, lines[i])
-		if lines[i] == "" { // empty line
-			bodyStart = i + 1
-			break
+}
+
+// contentLength parses the Content-Length header(s). Repeating the header is fine, as long as every copy agrees.
+func contentLength(headers Headers) (int64, error) {
+	vals := headers.Values("Content-Length")
+	for _, v := range vals[1:] {
+		if v != vals[0] {
+			return 0, fmt.Errorf("conflicting Content-Length headers %q and %q", vals[0], v)
 		}
-		key, val, ok := strings.Cut(lines[i], ": ")
-		if !ok {
-			return nil, fmt.Errorf("malformed response: header %q should be of form 'key: value'", lines[i])
+	}
+	v := vals[0]
+	for i := 0; i < len(v); i++ { // strconv would accept a leading '+' or '-'; it's 1*DIGIT.
+		if !isDigit(v[i]) {
+			return 0, fmt.Errorf("should be a non-negative integer")
 		}
-		key = AsTitle(key)
-		resp.Headers = append(resp.Headers, Header{key, val})
 	}
-	resp.Body = strings.TrimSpace(strings.Join(lines[bodyStart:], "\r\n")) // recombine the body using normal newlines.
-	return resp, nil
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("should be a non-negative integer")
+	}
+	return n, nil
 }
 
-// splitLines on the "\r\n" sequence; multiple separators in a row are NOT collapsed.
-func splitLines(s string) []string {
-	if s == "" {
-		return nil
+// msgReader reads a message from a bufio.Reader, keeping track of where it is, so errors can point at the problem.
+type msgReader struct {
+	br      *bufio.Reader
+	off     int64 // bytes read so far
+	line    int   // number of the last line read by readLine, counting from 1
+	lineOff int64 // offset of the start of that line
+}
+
+// Read reads body data. It doesn't count lines: they don't mean anything in the body.
+func (mr *msgReader) Read(p []byte) (int, error) {
+	n, err := mr.br.Read(p)
+	mr.off += int64(n)
+	return n, err
+}
+
+// errLineTooLong is returned by readLine; callers translate it into the limit error that makes sense for them.
+var errLineTooLong = errors.New("line too long")
+
+// readLine reads a single line, up to but not including the line terminator.
+// The terminator should be "\r\n", but we accept a bare "\n" too, as RFC 9112 section 2.2 allows.
+// It returns io.EOF only if there was nothing left to read; a line cut off by EOF is io.ErrUnexpectedEOF.
+// Lines longer than max bytes, counting the terminator, fail with errLineTooLong; -1 means no limit.
+func (mr *msgReader) readLine(max int) (string, error) {
+	mr.line++
+	mr.lineOff = mr.off
+	var line []byte
+	for {
+		chunk, err := mr.br.ReadSlice('\n')
+		mr.off += int64(len(chunk))
+		if exceeds(len(line)+len(chunk), max) {
+			return "", errLineTooLong
+		}
+		line = append(line, chunk...) // ReadSlice's result is only valid until the next read, so we copy it.
+		if err == bufio.ErrBufferFull {
+			continue // line is longer than the buffer: keep going.
+		}
+		if err == io.EOF && len(line) > 0 {
+			return "", io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return "", err
+		}
+		line = bytes.TrimSuffix(line, []byte("\n"))
+		return string(bytes.TrimSuffix(line, []byte("\r"))), nil
 	}
-	var lines []string
-	i := 0
+}
+
+// readStartLine reads the first line of a request or response, which can be at most max bytes long.
+// RFC 9112 section 2.2 says we SHOULD ignore at least one empty line before it, so we skip them all,
+// and restart the count so the start line is always line 1.
+func (mr *msgReader) readStartLine(max int) (string, error) {
 	for {
-		j := strings.Index(s[i:], "\r\n")
-		if j == -1 {
-			lines = append(lines, s[i:])
-			return lines
+		mr.line, mr.off = 0, 0
+		line, err := mr.readLine(max)
+		if err == errLineTooLong {
+			return "", mr.errorf(ErrRequestLineTooLong, "", "start line is limited to %d bytes", max)
+		}
+		if err != nil || line != "" {
+			return line, err
 		}
-		lines = append(lines, s[i:i+j]) // up to but not including the \r\n
-		i += j + 2                      // skip the \r\n
 	}
 }
+
+// errorf returns a ParseError about the last line read.
+func (mr *msgReader) errorf(kind error, input, format string, args ...any) *ParseError {
+	return &ParseError{Kind: kind, Line: mr.line, Offset: mr.lineOff, Input: truncate(input), Reason: fmt.Sprintf(format, args...)}
+}
+
+// bodyErrorf returns a ParseError about the body data at the current offset.
+func (mr *msgReader) bodyErrorf(kind error, format string, args ...any) *ParseError {
+	return &ParseError{Kind: kind, Offset: mr.off, Reason: fmt.Sprintf(format, args...)}
+}
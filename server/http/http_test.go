@@ -1,7 +1,10 @@
 package http
 
 import (
+	"bufio"
+	"io"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -14,6 +17,7 @@ func TestHTTPResponse(t *testing.T) {
 			input: "HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n",
 			want: &Response{
 				StatusCode: 200,
+				Proto:      "HTTP/1.1",
 				Headers: []Header{
 					{"Content-Length", "0"},
 				},
@@ -23,10 +27,12 @@ func TestHTTPResponse(t *testing.T) {
 			input: "HTTP/1.1 404 Not Found\r\nContent-Length: 11\r\n\r\nHello World\r\n",
 			want: &Response{
 				StatusCode: 404,
+				Proto:      "HTTP/1.1",
 				Headers: []Header{
 					{"Content-Length", "11"},
 				},
-				Body: "Hello World",
+				Body:          newMemBody([]byte("Hello World")),
+				ContentLength: 11,
 			},
 		},
 	} {
@@ -59,6 +65,7 @@ func TestHTTPRequest(t *testing.T) {
 			want: Request{
 				Method: "GET",
 				Path:   "/",
+				Proto:  "HTTP/1.1",
 				Headers: []Header{
 					{"Host", "www.example.com"},
 				},
@@ -69,11 +76,13 @@ func TestHTTPRequest(t *testing.T) {
 			want: Request{
 				Method: "POST",
 				Path:   "/",
+				Proto:  "HTTP/1.1",
 				Headers: []Header{
 					{"Host", "www.example.com"},
 					{"Content-Length", "11"},
 				},
-				Body: "Hello World",
+				Body:          newMemBody([]byte("Hello World")),
+				ContentLength: 11,
 			},
 		},
 	} {
@@ -97,3 +106,69 @@ func TestHTTPRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestReadRequestSequence(t *testing.T) {
+	// two requests back to back on the same reader, like a client reusing a connection.
+	const raw = "POST /a HTTP/1.1\r\nHost: www.example.com\r\nContent-Length: 5\r\n\r\nhello" +
+		"GET /b HTTP/1.1\r\nHost: www.example.com\r\n\r\n"
+	br := bufio.NewReader(strings.NewReader(raw))
+	for _, want := range []Request{
+		{Method: "POST", Path: "/a", Proto: "HTTP/1.1", Headers: []Header{{"Host", "www.example.com"}, {"Content-Length", "5"}}, Body: newMemBody([]byte("hello")), ContentLength: 5},
+		{Method: "GET", Path: "/b", Proto: "HTTP/1.1", Headers: []Header{{"Host", "www.example.com"}}},
+	} {
+		got, err := ReadRequest(br)
+		if err != nil {
+			t.Fatalf("ReadRequest() returned error: %v", err)
+		}
+		if _, err := got.BodyBytes(); err != nil { // the body streams: it has to be read before the next request.
+			t.Fatalf("BodyBytes() returned error: %v", err)
+		}
+		if !reflect.DeepEqual(*got, want) {
+			t.Errorf("ReadRequest() = %+v, want %+v", *got, want)
+		}
+	}
+	if _, err := ReadRequest(br); err != io.EOF {
+		t.Errorf("ReadRequest() at end of input returned error %v, want io.EOF", err)
+	}
+}
+
+func TestReadResponseUntilEOF(t *testing.T) {
+	// without a Content-Length, the body runs until the connection closes.
+	got, err := ReadResponse(bufio.NewReader(strings.NewReader("HTTP/1.1 200 OK\r\nServer: rochi\r\n\r\nsome body")))
+	if err != nil {
+		t.Fatalf("ReadResponse() returned error: %v", err)
+	}
+	if got.ContentLength != -1 {
+		t.Errorf("ReadResponse().ContentLength = %d, want -1", got.ContentLength)
+	}
+	if body := bodyOf(t, got); body != "some body" {
+		t.Errorf("ReadResponse().Body = %q, want %q", body, "some body")
+	}
+}
+
+func TestBodyIsByteExact(t *testing.T) {
+	// whitespace and binary data should come through untouched.
+	const payload = "  \r\n\x00\xff binary \t\r\n\r\n"
+	resp, _ := NewResponse(200, payload)
+	got, err := ParseResponse(resp.String())
+	if err != nil {
+		t.Fatalf("ParseResponse(%q) returned error: %v", resp.String(), err)
+	}
+	if body := bodyOf(t, got); body != payload {
+		t.Errorf("body = %q, want %q", body, payload)
+	}
+	// String() shouldn't use up an in-memory body.
+	if first, second := resp.String(), resp.String(); first != second {
+		t.Errorf("String() changed between calls: %q, then %q", first, second)
+	}
+}
+
+// bodyOf reads the whole body of resp, failing the test if it can't.
+func bodyOf(t *testing.T, resp *Response) string {
+	t.Helper()
+	b, err := resp.BodyBytes()
+	if err != nil {
+		t.Fatalf("reading body: %v", err)
+	}
+	return string(b)
+}
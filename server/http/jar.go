@@ -0,0 +1,170 @@
+package http
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookieJar stores cookies the Client receives, and hands them back for the requests they apply to.
+type CookieJar interface {
+	// SetCookies stores the cookies received in a response to a request for u.
+	SetCookies(u *url.URL, cookies []*Cookie)
+	// Cookies returns the cookies to send with a request for u.
+	Cookies(u *url.URL) []*Cookie
+}
+
+// Jar is an in-memory CookieJar following the storage model of RFC 6265 section 5.3:
+//   - a cookie without a Domain goes back only to the host that set it. One with a Domain goes to that domain and
+//     its subdomains, but it's only accepted from a host within that domain, and not for a bare top-level domain
+//     like "com". (There's no public suffix list, so "co.uk" gets through.)
+//   - a cookie without a Path gets the "directory" of the request path, e.g "/a" for "/a/b".
+//     It goes back to requests for that path and anything under it.
+//   - a Secure cookie only goes back over https; an expired one doesn't go back at all, and replaces,
+//     i.e. deletes, any cookie with the same name, domain and path.
+//
+// The zero value is an empty jar, ready to use. A Jar is safe for concurrent use.
+type Jar struct {
+	mu      sync.Mutex
+	entries map[jarKey]*jarEntry
+	seq     uint64 // creation order, to break ties when sorting
+}
+
+// jarKey identifies a cookie in the jar: a new one with the same key replaces the old one.
+type jarKey struct{ name, domain, path string }
+
+type jarEntry struct {
+	cookie   Cookie
+	hostOnly bool      // no Domain attribute: only sent to cookie.Domain itself, the host that set it
+	expires  time.Time // zero for session cookies
+	seq      uint64
+}
+
+// NewJar returns an empty Jar.
+func NewJar() *Jar { return new(Jar) }
+
+// SetCookies implements CookieJar.
+func (j *Jar) SetCookies(u *url.URL, cookies []*Cookie) {
+	host := canonicalHost(u.Host)
+	if host == "" {
+		return
+	}
+	now := time.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, c := range cookies {
+		e := &jarEntry{cookie: *c}
+		switch domain := strings.ToLower(strings.TrimPrefix(c.Domain, ".")); {
+		case domain == "" || domain == host:
+			e.cookie.Domain, e.hostOnly = host, domain == ""
+		case !domainMatch(host, domain) || !strings.Contains(domain, "."):
+			continue // a host can't set cookies for another domain, or for a whole top-level domain.
+		default:
+			e.cookie.Domain = domain
+		}
+		if e.cookie.Path == "" || e.cookie.Path[0] != '/' {
+			e.cookie.Path = defaultCookiePath(u.Path)
+		}
+		switch {
+		case c.MaxAge < 0:
+			e.expires = now // i.e. already expired.
+		case c.MaxAge > 0:
+			e.expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		default:
+			e.expires = c.Expires
+		}
+		key := jarKey{e.cookie.Name, e.cookie.Domain, e.cookie.Path}
+		if !e.expires.IsZero() && !e.expires.After(now) {
+			delete(j.entries, key)
+			continue
+		}
+		if old, ok := j.entries[key]; ok {
+			e.seq = old.seq // RFC 6265 section 5.3 step 11: keep the original creation time.
+		} else {
+			j.seq++
+			e.seq = j.seq
+		}
+		if j.entries == nil {
+			j.entries = make(map[jarKey]*jarEntry)
+		}
+		j.entries[key] = e
+	}
+}
+
+// Cookies implements CookieJar. Cookies with longer paths come first, then older ones, as RFC 6265 section 5.4
+// recommends. Only the Name and Value are filled in, since that's all that's sent.
+func (j *Jar) Cookies(u *url.URL) []*Cookie {
+	host := canonicalHost(u.Host)
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	now := time.Now()
+	j.mu.Lock()
+	var matched []*jarEntry
+	for key, e := range j.entries {
+		switch {
+		case !e.expires.IsZero() && !e.expires.After(now):
+			delete(j.entries, key) // clean up as we go.
+		case e.cookie.Secure && u.Scheme != "https":
+		case e.hostOnly && host != e.cookie.Domain, !e.hostOnly && !domainMatch(host, e.cookie.Domain):
+		case !pathMatch(path, e.cookie.Path):
+		default:
+			matched = append(matched, e)
+		}
+	}
+	j.mu.Unlock()
+	sort.Slice(matched, func(a, b int) bool {
+		if la, lb := len(matched[a].cookie.Path), len(matched[b].cookie.Path); la != lb {
+			return la > lb
+		}
+		return matched[a].seq < matched[b].seq
+	})
+	cookies := make([]*Cookie, len(matched))
+	for i, e := range matched {
+		cookies[i] = &Cookie{Name: e.cookie.Name, Value: e.cookie.Value}
+	}
+	return cookies
+}
+
+// canonicalHost lowercases the host of a URL and strips its port, e.g "Example.COM:8080" is "example.com".
+func canonicalHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.Trim(host, "[]"))
+}
+
+// domainMatch reports whether host is domain, or a subdomain of it (RFC 6265 section 5.1.3).
+// IP addresses only match themselves.
+func domainMatch(host, domain string) bool {
+	if host == domain {
+		return true
+	}
+	return strings.HasSuffix(host, "."+domain) && net.ParseIP(host) == nil
+}
+
+// pathMatch reports whether the request path is the cookie path, or under it (RFC 6265 section 5.1.4).
+func pathMatch(path, cookiePath string) bool {
+	switch {
+	case path == cookiePath:
+		return true
+	case !strings.HasPrefix(path, cookiePath):
+		return false
+	default:
+		return strings.HasSuffix(cookiePath, "/") || path[len(cookiePath)] == '/'
+	}
+}
+
+// defaultCookiePath is the path a cookie gets if it doesn't say (RFC 6265 section 5.1.4): the request path
+// up to, but not including, its last '/', e.g "/a" for "/a/b", and "/" for "/a".
+func defaultCookiePath(path string) string {
+	i := strings.LastIndexByte(path, '/')
+	if i <= 0 {
+		return "/"
+	}
+	return path[:i]
+}
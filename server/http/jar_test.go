@@ -0,0 +1,96 @@
+package http
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestJar(t *testing.T) {
+	type set struct {
+		url    string
+		cookie string // as in a Set-Cookie header
+	}
+	for name, tt := range map[string]struct {
+		set  []set
+		url  string
+		want []string // name=value, in the order they should be sent
+	}{
+		"host only":             {[]set{{"http://example.com/", "a=1"}}, "http://example.com/", []string{"a=1"}},
+		"host only, subdomain":  {[]set{{"http://example.com/", "a=1"}}, "http://www.example.com/", nil},
+		"host only, port":       {[]set{{"http://example.com:8080/", "a=1"}}, "http://example.com:9090/", []string{"a=1"}},
+		"domain, subdomain":     {[]set{{"http://example.com/", "a=1; Domain=example.com"}}, "http://www.example.com/", []string{"a=1"}},
+		"domain from subdomain": {[]set{{"http://www.example.com/", "a=1; Domain=.example.com"}}, "http://api.example.com/", []string{"a=1"}},
+		"domain, other site":    {[]set{{"http://example.com/", "a=1; Domain=example.com"}}, "http://notexample.com/", nil},
+		"foreign domain":        {[]set{{"http://example.com/", "a=1; Domain=other.com"}}, "http://other.com/", nil},
+		"top-level domain":      {[]set{{"http://example.com/", "a=1; Domain=com"}}, "http://other.com/", nil},
+		"IP domain":             {[]set{{"http://127.0.0.1/", "a=1; Domain=0.0.1"}}, "http://127.0.0.1/", nil},
+		"path":                  {[]set{{"http://example.com/", "a=1; Path=/app"}}, "http://example.com/app/page", []string{"a=1"}},
+		"path, sibling":         {[]set{{"http://example.com/", "a=1; Path=/app"}}, "http://example.com/apple", nil},
+		"path, parent":          {[]set{{"http://example.com/", "a=1; Path=/app"}}, "http://example.com/", nil},
+		"default path":          {[]set{{"http://example.com/app/login", "a=1"}}, "http://example.com/app/home", []string{"a=1"}},
+		"default path, outside": {[]set{{"http://example.com/app/login", "a=1"}}, "http://example.com/other", nil},
+		"secure over http":      {[]set{{"https://example.com/", "a=1; Secure"}}, "http://example.com/", nil},
+		"secure over https":     {[]set{{"https://example.com/", "a=1; Secure"}}, "https://example.com/", []string{"a=1"}},
+		"expired":               {[]set{{"http://example.com/", "a=1; Expires=Thu, 01 Jan 1970 00:00:00 GMT"}}, "http://example.com/", nil},
+		"deleted": {
+			[]set{{"http://example.com/", "a=1"}, {"http://example.com/", "a=; Max-Age=0"}},
+			"http://example.com/", nil,
+		},
+		"replaced": {
+			[]set{{"http://example.com/", "a=1"}, {"http://example.com/", "a=2"}},
+			"http://example.com/", []string{"a=2"},
+		},
+		"order": {
+			[]set{{"http://example.com/", "a=1; Path=/"}, {"http://example.com/", "b=2; Path=/x/y"}, {"http://example.com/", "c=3; Path=/x"}, {"http://example.com/", "d=4; Path=/"}},
+			"http://example.com/x/y/z", []string{"b=2", "c=3", "a=1", "d=4"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			jar := NewJar()
+			for _, s := range tt.set {
+				u, _ := url.Parse(s.url)
+				c, ok := parseSetCookie(s.cookie)
+				if !ok {
+					t.Fatalf("bad Set-Cookie %q", s.cookie)
+				}
+				jar.SetCookies(u, []*Cookie{c})
+			}
+			u, _ := url.Parse(tt.url)
+			var got []string
+			for _, c := range jar.Cookies(u) {
+				got = append(got, c.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientJar(t *testing.T) {
+	mux := NewMux()
+	mux.HandleFunc("GET", "/login", func(r *Request) *Response {
+		resp, _ := NewResponse(302, "")
+		resp.SetCookie(&Cookie{Name: "session", Value: "abc", Path: "/"})
+		return resp.WithHeader("Location", "/home")
+	})
+	mux.HandleFunc("GET", "/home", func(r *Request) *Response {
+		resp, _ := NewResponse(200, "cookie="+r.Headers.Get("Cookie"))
+		return resp
+	})
+	addr := startServer(t, &Server{Handler: mux})
+	client := &Client{Jar: NewJar()}
+
+	for _, path := range []string{"/login", "/home"} {
+		req, _ := NewRequest("GET", path, addr, "")
+		resp, err := client.Do(context.Background(), req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		if body, want := bodyOf(t, resp), "cookie=session=abc"; body != want {
+			t.Errorf("GET %s: got %q, want %q", path, body, want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package http
+
+import "errors"
+
+// Default limits, used by a Parser whose corresponding field is zero.
+const (
+	DefaultMaxRequestLineBytes = 8 << 10  // 8 KiB; URLs longer than that are almost certainly abuse.
+	DefaultMaxHeaderBytes      = 64 << 10 // 64 KiB
+	DefaultMaxHeaderCount      = 100
+	DefaultMaxBodyBytes        = 10 << 20 // 10 MiB
+)
+
+// Errors returned by a Parser when a message exceeds its limits. Use errors.Is to check for them.
+// The Server answers them with 414 URI Too Long, 431 Request Header Fields Too Large and 413 Content Too Large.
+var (
+	ErrRequestLineTooLong = errors.New("request line too long")
+	ErrHeaderTooLarge     = errors.New("header section too large")
+	ErrTooManyHeaders     = errors.New("too many header fields")
+	ErrBodyTooLarge       = errors.New("body too large")
+)
+
+// Parser reads requests and responses, refusing any that are malformed or exceed its limits, so a misbehaving peer can't make us
+// buffer an unbounded amount of data. For every limit, zero means the default and a negative value means no limit.
+// The zero Parser is ready to use; it's what the package-level ReadRequest and ReadResponse use.
+type Parser struct {
+	MaxRequestLineBytes int   // longest request line (or status line, for responses), including the CRLF
+	MaxHeaderBytes      int   // most bytes in the header section, including every CRLF; also applies to trailers
+	MaxHeaderCount      int   // most header fields in the header section; also applies to trailers
+	MaxBodyBytes        int64 // longest request body. Response bodies aren't limited: a client usually wants all of it
+
+	// Strict rejects obsolete line folding (a header line starting with whitespace, continuing the previous one)
+	// with ErrObsFold. Otherwise, folded lines are unfolded into a single space, as RFC 9112 section 5.2 allows.
+	Strict bool
+}
+
+func (p *Parser) maxRequestLineBytes() int {
+	return limit(p.MaxRequestLineBytes, DefaultMaxRequestLineBytes)
+}
+func (p *Parser) maxHeaderBytes() int { return limit(p.MaxHeaderBytes, DefaultMaxHeaderBytes) }
+func (p *Parser) maxHeaderCount() int { return limit(p.MaxHeaderCount, DefaultMaxHeaderCount) }
+func (p *Parser) maxBodyBytes() int64 { return limit(p.MaxBodyBytes, DefaultMaxBodyBytes) }
+
+// limit returns the effective value of a limit: def if it's zero, or -1 (no limit) if it's negative.
+func limit[T int | int64](v, def T) T {
+	switch {
+	case v == 0:
+		return def
+	case v < 0:
+		return -1
+	default:
+		return v
+	}
+}
+
+// exceeds reports whether n is over the effective limit max; -1 means no limit.
+func exceeds[T int | int64](n, max T) bool { return max >= 0 && n > max }
+
+// statusForError picks the status code the server should answer a request it couldn't read with.
+func statusForError(err error) int {
+	switch {
+	case errors.Is(err, ErrRequestLineTooLong):
+		return 414
+	case errors.Is(err, ErrHeaderTooLarge), errors.Is(err, ErrTooManyHeaders):
+		return 431
+	case errors.Is(err, ErrBodyTooLarge):
+		return 413
+	case errors.Is(err, ErrUnsupportedVersion):
+		return 505
+	default:
+		return 400
+	}
+}
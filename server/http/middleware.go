@@ -0,0 +1,159 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Middleware wraps a Handler to add behavior before and/or after it, e.g logging or authentication.
+type Middleware func(next Handler) Handler
+
+// Chain wraps h in the given middleware. The first middleware is the outermost:
+// it sees the request first and the response last. That is,
+//
+//	Chain(h, Recover, RequestID, Timeout(time.Second))
+//
+// is the same as Recover(RequestID(Timeout(time.Second)(h))).
+func Chain(h Handler, mw ...Middleware) Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
+// Recover turns a panic in next into a 500 Internal Server Error, logging the panic and the stack trace to the
+// ErrorLog of the Server the request came from, or the log package's standard logger if there's none.
+// Put it first in the Chain so it covers the other middleware, too.
+func Recover(next Handler) Handler {
+	return HandlerFunc(func(r *Request) (resp *Response) {
+		defer func() {
+			if p := recover(); p != nil {
+				logf := log.Printf
+				if r.srv != nil {
+					logf = r.srv.logf
+				}
+				logf("panic serving %s %s: %v\n%s", r.Method, r.Path, p, debug.Stack())
+				resp, _ = NewResponse(500, "")
+			}
+		}()
+		return next.ServeHTTP(r)
+	})
+}
+
+// RequestIDHeader is the header RequestID reads and writes.
+const RequestIDHeader = "X-Request-Id"
+
+// RequestID makes sure every request has an X-Request-Id header, generating a random one if the client didn't send it,
+// so next (and anything it logs) can tell requests apart. The same ID is sent back on the response.
+func RequestID(next Handler) Handler {
+	return HandlerFunc(func(r *Request) *Response {
+		id := r.Headers.Get(RequestIDHeader)
+		if id == "" {
+			id = newRequestID()
+			r.Headers.Set(RequestIDHeader, id)
+		}
+		resp := next.ServeHTTP(r)
+		if resp != nil && !resp.Headers.Has(RequestIDHeader) {
+			resp.Headers.Set(RequestIDHeader, id)
+		}
+		return resp
+	})
+}
+
+// errHandlerTimeout is returned by reading the body of a request whose handler Timeout has given up on.
+var errHandlerTimeout = errors.New("http: handler timed out")
+
+// abandonableBody is a request body that fails to read once abandoned, so a handler that's been given up on
+// doesn't go on reading from the connection.
+type abandonableBody struct {
+	rc        io.ReadCloser
+	mu        sync.Mutex
+	abandoned bool
+}
+
+func (b *abandonableBody) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	abandoned := b.abandoned
+	b.mu.Unlock()
+	if abandoned {
+		return 0, errHandlerTimeout
+	}
+	return b.rc.Read(p)
+}
+
+func (b *abandonableBody) Close() error { return b.rc.Close() }
+
+func (b *abandonableBody) abandon() {
+	b.mu.Lock()
+	b.abandoned = true
+	b.mu.Unlock()
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand should never fail, but an ID that's merely unlikely to be unique beats none.
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// Timeout gives next at most d to respond. The request's context is canceled after d, and if next still hasn't
+// returned, the client gets a 503 Service Unavailable instead; whatever next eventually returns is thrown away.
+// Handlers doing long work should watch r.Context().Done() and give up early.
+//
+// next gets a copy of the request headers, so changing them changes nothing for the middleware outside of Timeout.
+// Once next is given up on, reading the request body fails, and the 503 says "Connection: close": next may still be
+// in the middle of a read, so the Server can't skip over the rest of the body to reuse the connection.
+func Timeout(d time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(r *Request) *Response {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			type result struct {
+				resp  *Response
+				panic any
+			}
+			r2 := r.WithContext(ctx)
+			// next may carry on after it's given up on, while the Server still has r: they can't share the headers.
+			r2.Headers = r.Headers.Clone()
+			var body *abandonableBody
+			if r.Body != nil {
+				body = &abandonableBody{rc: r.Body}
+				r2.Body = body
+			}
+			done := make(chan result, 1) // buffered, so a late handler doesn't leak blocked on the send.
+			go func() {
+				var res result
+				defer func() {
+					res.panic = recover()
+					done <- res
+				}()
+				res.resp = next.ServeHTTP(r2)
+			}()
+
+			select {
+			case res := <-done:
+				if res.panic != nil {
+					panic(res.panic) // re-panic on the caller's goroutine, where Recover can see it.
+				}
+				return res.resp
+			case <-ctx.Done():
+				if body != nil {
+					body.abandon()
+				}
+				resp, _ := NewResponse(503, "")
+				return resp.WithHeader("Connection", "close")
+			}
+		})
+	}
+}
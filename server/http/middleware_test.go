@@ -0,0 +1,150 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return HandlerFunc(func(r *Request) *Response {
+				order = append(order, "> "+name)
+				resp := next.ServeHTTP(r)
+				order = append(order, "< "+name)
+				return resp
+			})
+		}
+	}
+	h := Chain(HandlerFunc(func(r *Request) *Response {
+		order = append(order, "handler")
+		return nil
+	}), trace("a"), trace("b"))
+	h.ServeHTTP(&Request{})
+
+	if got, want := strings.Join(order, ", "), "> a, > b, handler, < b, < a"; got != want {
+		t.Errorf("order = %q, want %q", got, want)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	h := Chain(HandlerFunc(func(r *Request) *Response { panic("oops") }), Recover)
+	if resp := h.ServeHTTP(&Request{}); resp == nil || resp.StatusCode != 500 {
+		t.Errorf("got %+v, want a 500", resp)
+	}
+	// a panic inside a timed-out handler should make it to Recover, too.
+	h = Chain(HandlerFunc(func(r *Request) *Response { panic("oops") }), Recover, Timeout(time.Second))
+	if resp := h.ServeHTTP(&Request{}); resp == nil || resp.StatusCode != 500 {
+		t.Errorf("with Timeout, got %+v, want a 500", resp)
+	}
+	// on a Server, the panic goes to its ErrorLog.
+	var buf bytes.Buffer
+	addr := startServer(t, &Server{Handler: h, ErrorLog: log.New(&buf, "", 0)})
+	if resp := roundTrip(t, addr, "GET /boom HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n"); resp.StatusCode != 500 {
+		t.Errorf("on a Server, got %d, want a 500", resp.StatusCode)
+	}
+	if got := buf.String(); !strings.HasPrefix(got, "panic serving GET /boom: oops") {
+		t.Errorf("got log %q, want the panic", got)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	h := RequestID(HandlerFunc(func(r *Request) *Response {
+		seen = r.Headers.Get(RequestIDHeader)
+		resp, _ := NewResponse(200, "")
+		return resp
+	}))
+
+	resp := h.ServeHTTP(&Request{})
+	if seen == "" || resp.Headers.Get(RequestIDHeader) != seen {
+		t.Errorf("generated ID: handler saw %q, response has %q", seen, resp.Headers.Get(RequestIDHeader))
+	}
+	resp = h.ServeHTTP(&Request{Headers: Headers{{RequestIDHeader, "from-client"}}})
+	if seen != "from-client" || resp.Headers.Get(RequestIDHeader) != "from-client" {
+		t.Errorf("client ID: handler saw %q, response has %q", seen, resp.Headers.Get(RequestIDHeader))
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	h := Timeout(10 * time.Millisecond)(HandlerFunc(func(r *Request) *Response {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+			t.Error("handler's context wasn't canceled")
+		}
+		resp, _ := NewResponse(200, "")
+		return resp
+	}))
+	if resp := h.ServeHTTP(&Request{}); resp.StatusCode != 503 {
+		t.Errorf("slow handler: got %d, want 503", resp.StatusCode)
+	}
+
+	h = Timeout(time.Second)(HandlerFunc(func(r *Request) *Response {
+		resp, _ := NewResponse(200, "")
+		return resp
+	}))
+	if resp := h.ServeHTTP(&Request{}); resp.StatusCode != 200 {
+		t.Errorf("fast handler: got %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestTimeoutCopiesHeaders(t *testing.T) {
+	late := make(chan struct{})
+	h := Timeout(10 * time.Millisecond)(HandlerFunc(func(r *Request) *Response {
+		<-r.Context().Done()
+		r.Headers.Set("Accept", "text/late")
+		r.Headers.Add("X-Late", "1")
+		close(late)
+		return nil
+	}))
+	req := &Request{Headers: Headers{{"Accept", "*/*"}}}
+	if resp := h.ServeHTTP(req); resp.StatusCode != 503 {
+		t.Fatalf("got %d, want 503", resp.StatusCode)
+	}
+	<-late
+	if want := (Headers{{"Accept", "*/*"}}); !reflect.DeepEqual(req.Headers, want) {
+		t.Errorf("got headers %v after the abandoned handler changed its own, want %v", req.Headers, want)
+	}
+}
+
+func TestTimeoutAbandonsBody(t *testing.T) {
+	lateRead := make(chan error, 1)
+	h := Timeout(20 * time.Millisecond)(HandlerFunc(func(r *Request) *Response {
+		<-r.Context().Done()
+		time.Sleep(50 * time.Millisecond) // until after the 503's gone out.
+		_, err := io.ReadAll(r.Body)
+		lateRead <- err
+		return nil
+	}))
+	addr := startServer(t, &Server{Handler: h})
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	// the body follows later, so the server would still be draining it as the handler starts reading it.
+	conn.Write([]byte("PUT / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 10\r\n\r\nhello"))
+	go func() {
+		time.Sleep(60 * time.Millisecond)
+		conn.Write([]byte("world" + "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	}()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	got, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("the server didn't hang up: %v", err)
+	}
+	if want := "HTTP/1.1 503 Service Unavailable\r\nContent-Length: 19\r\nConnection: close\r\n\r\nService Unavailable"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if err := <-lateRead; err == nil {
+		t.Error("the abandoned handler could still read the body")
+	}
+}
@@ -0,0 +1,243 @@
+package http
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Mux is a Handler that routes requests by method and path pattern. A pattern is a path whose segments are either
+//   - literal, e.g "users", which matches only itself
+//   - a parameter, e.g "{id}", which matches any single non-empty segment
+//   - a wildcard, e.g "*rest", which matches the rest of the path, slashes and all. It must be the last segment.
+//
+// So "/users/{id}" matches "/users/42", and "/static/*rest" matches "/static/css/main.css".
+// Handlers read the matched values with Request.Param. When more than one pattern matches, the most specific wins:
+// literals beat parameters, which beat wildcards, comparing segments left to right.
+//
+// A path that matches some pattern, but not for the request's method, gets a 405 Method Not Allowed listing the
+// methods that would work in the Allow header. A path that matches nothing gets a 404 Not Found.
+//
+// HEAD and OPTIONS are handled automatically, unless there's a route for them: a HEAD request goes to the GET route,
+// and the Server leaves the body out of the response; an OPTIONS request gets a 204 No Content with the Allow header,
+// and "OPTIONS *" lists the methods of every route.
+type Mux struct {
+	routes []*route
+}
+
+type route struct {
+	method  string
+	pattern string
+	segs    []segment
+	handler Handler
+}
+
+type segmentKind int
+
+// in order of increasing specificity.
+const (
+	wildcardSegment segmentKind = iota
+	paramSegment
+	literalSegment
+)
+
+type segment struct {
+	kind segmentKind
+	text string // the literal text, or the name of the parameter or wildcard.
+}
+
+// NewMux returns an empty Mux.
+func NewMux() *Mux { return new(Mux) }
+
+// Handle registers h for requests with the given method whose path matches pattern.
+// It panics if the pattern is malformed or already registered for that method, since that's a programming error.
+func (m *Mux) Handle(method, pattern string, h Handler) {
+	if method == "" {
+		panic("http: Mux.Handle: empty method")
+	}
+	if h == nil {
+		panic("http: Mux.Handle: nil handler")
+	}
+	segs, err := parsePattern(pattern)
+	if err != nil {
+		panic(fmt.Sprintf("http: Mux.Handle: %v", err))
+	}
+	for _, rt := range m.routes {
+		if rt.method == method && rt.pattern == pattern {
+			panic(fmt.Sprintf("http: Mux.Handle: %s %s registered twice", method, pattern))
+		}
+	}
+	m.routes = append(m.routes, &route{method: method, pattern: pattern, segs: segs, handler: h})
+}
+
+// HandleFunc registers f for requests with the given method whose path matches pattern. See Handle.
+func (m *Mux) HandleFunc(method, pattern string, f func(r *Request) *Response) {
+	m.Handle(method, pattern, HandlerFunc(f))
+}
+
+func (m *Mux) ServeHTTP(r *Request) *Response {
+	allowed := make(map[string]bool)
+	if r.Path == "*" {
+		// "OPTIONS *" asks about the server as a whole: everything any route allows.
+		for _, rt := range m.routes {
+			allowed[rt.method] = true
+		}
+		return optionsResponse(allowed)
+	}
+	path := splitPath(r.Path)
+	best, params := m.find(r.Method, path, allowed)
+	if best == nil && r.Method == "HEAD" {
+		best, params = m.find("GET", path, nil) // HEAD is GET without the body, which the Server leaves out.
+	}
+	switch {
+	case best != nil:
+		r.params = params
+		return best.handler.ServeHTTP(r)
+	case len(allowed) > 0 && r.Method == "OPTIONS":
+		return optionsResponse(allowed)
+	case len(allowed) > 0:
+		resp, _ := NewResponse(405, "")
+		return resp.WithHeader("Allow", allowHeader(allowed))
+	default:
+		resp, _ := NewResponse(404, "")
+		return resp
+	}
+}
+
+// find returns the most specific route for method whose pattern matches path, and the parameter values it matched.
+// If allowed isn't nil, the methods of every route matching path are added to it.
+func (m *Mux) find(method string, path []string, allowed map[string]bool) (*route, map[string]string) {
+	var best *route
+	var bestParams map[string]string
+	for _, rt := range m.routes {
+		params, ok := rt.match(path)
+		if !ok {
+			continue
+		}
+		if allowed != nil {
+			allowed[rt.method] = true
+		}
+		if rt.method == method && (best == nil || moreSpecific(rt.segs, best.segs)) {
+			best, bestParams = rt, params
+		}
+	}
+	return best, bestParams
+}
+
+// optionsResponse answers an OPTIONS request with the allowed methods, and no body.
+func optionsResponse(allowed map[string]bool) *Response {
+	resp, _ := NewResponse(204, "")
+	return resp.WithHeader("Allow", allowHeader(allowed))
+}
+
+// Param returns the value of the named path parameter or wildcard matched by the Mux, or "" if there's none.
+// e.g, for the pattern "/users/{id}" and the path "/users/42", r.Param("id") is "42".
+func (r *Request) Param(name string) string { return r.params[name] }
+
+// StripPrefix returns a handler that serves requests by passing them to h with prefix removed from the start of
+// their Path, e.g to mount a FileServer under a wildcard:
+//
+//	mux.Handle("GET", "/static/*file", StripPrefix("/static", FileServer("/srv/www")))
+//
+// serves "/static/css/main.css" from "/srv/www/css/main.css". A request whose Path doesn't start with prefix
+// gets a 404 Not Found. h gets a copy of the request; the original is left as it is.
+func StripPrefix(prefix string, h Handler) Handler {
+	return HandlerFunc(func(r *Request) *Response {
+		rest := strings.TrimPrefix(r.Path, prefix)
+		if len(rest) == len(r.Path) && prefix != "" {
+			return errorResponse(404)
+		}
+		r2 := r.WithContext(r.Context())
+		r2.Path = rest
+		return h.ServeHTTP(r2)
+	})
+}
+
+// match reports whether the path segments match the route's pattern, returning the parameter values if so.
+func (rt *route) match(path []string) (map[string]string, bool) {
+	var params map[string]string
+	set := func(name, val string) {
+		if params == nil {
+			params = make(map[string]string)
+		}
+		params[name] = val
+	}
+	for i, seg := range rt.segs {
+		if i >= len(path) {
+			return nil, false
+		}
+		switch {
+		case seg.kind == wildcardSegment:
+			set(seg.text, strings.Join(path[i:], "/"))
+			return params, true
+		case seg.kind == literalSegment && path[i] != seg.text:
+			return nil, false
+		case seg.kind == paramSegment && path[i] == "":
+			return nil, false
+		case seg.kind == paramSegment:
+			set(seg.text, path[i])
+		}
+	}
+	return params, len(path) == len(rt.segs)
+}
+
+// moreSpecific returns true if the pattern a is more specific than b. See Mux.
+func moreSpecific(a, b []segment) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i].kind != b[i].kind {
+			return a[i].kind > b[i].kind
+		}
+	}
+	return len(a) > len(b)
+}
+
+func parsePattern(pattern string) ([]segment, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("pattern %q should start with /", pattern)
+	}
+	parts := splitPath(pattern)
+	segs := make([]segment, len(parts))
+	seen := make(map[string]bool)
+	for i, p := range parts {
+		switch {
+		case strings.HasPrefix(p, "*"):
+			if i != len(parts)-1 {
+				return nil, fmt.Errorf("pattern %q: wildcard %q should be the last segment", pattern, p)
+			}
+			segs[i] = segment{wildcardSegment, p[1:]}
+		case strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}"):
+			segs[i] = segment{paramSegment, p[1 : len(p)-1]}
+		default:
+			if strings.ContainsAny(p, "{}*") {
+				return nil, fmt.Errorf("pattern %q: malformed segment %q", pattern, p)
+			}
+			segs[i] = segment{literalSegment, p}
+			continue
+		}
+		if name := segs[i].text; name == "" || seen[name] {
+			return nil, fmt.Errorf("pattern %q: parameter names should be non-empty and unique", pattern)
+		}
+		seen[segs[i].text] = true
+	}
+	return segs, nil
+}
+
+// splitPath splits a path into its segments, e.g "/a/b/" is ["a", "b", ""] and "/" is [""].
+func splitPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "/"), "/")
+}
+
+// allowHeader formats the set of allowed methods for the Allow header, e.g "GET, HEAD, OPTIONS, POST".
+// HEAD is allowed wherever GET is, and OPTIONS everywhere, since the Mux answers those itself.
+func allowHeader(methods map[string]bool) string {
+	if methods["GET"] {
+		methods["HEAD"] = true
+	}
+	methods["OPTIONS"] = true
+	list := make([]string, 0, len(methods))
+	for m := range methods {
+		list = append(list, m)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ", ")
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMux(t *testing.T) {
+	m := NewMux()
+	handle := func(method, pattern string) {
+		m.HandleFunc(method, pattern, func(r *Request) *Response {
+			body := pattern
+			for _, name := range []string{"id", "rest"} {
+				if v := r.Param(name); v != "" {
+					body += fmt.Sprintf(" %s=%s", name, v)
+				}
+			}
+			resp, _ := NewResponse(200, body)
+			return resp
+		})
+	}
+	handle("GET", "/")
+	handle("GET", "/users/{id}")
+	handle("PUT", "/users/{id}")
+	handle("GET", "/users/me")
+	handle("GET", "/static/*rest")
+	handle("GET", "/static/favicon.ico")
+
+	for path, tt := range map[string]struct {
+		method     string
+		wantStatus int
+		wantBody   string
+		wantAllow  string
+	}{
+		"/":                    {"GET", 200, "/", ""},
+		"/users/42":            {"GET", 200, "/users/{id} id=42", ""},
+		"/users/me":            {"GET", 200, "/users/me", ""},         // literal beats parameter
+		"/users/43":            {"PUT", 200, "/users/{id} id=43", ""}, // same pattern, different method
+		"/users/":              {"GET", 404, "Not Found", ""},         // parameters don't match empty segments
+		"/users/42/extra":      {"GET", 404, "Not Found", ""},
+		"/static/css/main.css": {"GET", 200, "/static/*rest rest=css/main.css", ""},
+		"/static/favicon.ico":  {"GET", 200, "/static/favicon.ico", ""}, // literal beats wildcard
+		"/static":              {"GET", 404, "Not Found", ""},
+		"/users/44":            {"DELETE", 405, "Method Not Allowed", "GET, HEAD, OPTIONS, PUT"},
+		"/users/45":            {"HEAD", 200, "/users/{id} id=45", ""}, // the GET route; the Server drops the body
+		"/users/46":            {"OPTIONS", 204, "", "GET, HEAD, OPTIONS, PUT"},
+		"/nowhere":             {"OPTIONS", 404, "Not Found", ""},
+		"*":                    {"OPTIONS", 204, "", "GET, HEAD, OPTIONS, PUT"},
+	} {
+		t.Run(tt.method+" "+path, func(t *testing.T) {
+			resp := m.ServeHTTP(&Request{Method: tt.method, Path: path})
+			if body := bodyOf(t, resp); resp.StatusCode != tt.wantStatus || body != tt.wantBody {
+				t.Errorf("got %d %q, want %d %q", resp.StatusCode, body, tt.wantStatus, tt.wantBody)
+			}
+			if got := resp.Headers.Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+		})
+	}
+}
+
+func TestMuxBadPatterns(t *testing.T) {
+	for _, pattern := range []string{"users", "/*rest/more", "/{}", "/{id}/{id}", "/a{b}"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Handle(%q) didn't panic", pattern)
+				}
+			}()
+			NewMux().HandleFunc("GET", pattern, func(*Request) *Response { return nil })
+		}()
+	}
+}
+
+func TestStripPrefix(t *testing.T) {
+	echoPath := HandlerFunc(func(r *Request) *Response {
+		resp, _ := NewResponse(200, r.Path+"?"+r.RawQuery)
+		return resp
+	})
+	m := NewMux()
+	m.Handle("GET", "/static/*file", StripPrefix("/static", echoPath))
+	for name, tt := range map[string]struct {
+		h          Handler
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		"stripped":       {StripPrefix("/static", echoPath), "/static/css/main.css?v=2", 200, "/css/main.css?v=2"},
+		"whole path":     {StripPrefix("/static", echoPath), "/static?v=2", 200, "?v=2"},
+		"other prefix":   {StripPrefix("/static", echoPath), "/api/users", 404, "Not Found"},
+		"empty prefix":   {StripPrefix("", echoPath), "/a", 200, "/a?"},
+		"under a Mux":    {m, "/static/js/app.js", 200, "/js/app.js?"},
+		"not mounted on": {m, "/statics/app.js", 404, "Not Found"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, _ := NewRequest("GET", tt.path, "localhost", "")
+			resp := tt.h.ServeHTTP(req)
+			if got := bodyOf(t, resp); resp.StatusCode != tt.wantStatus || got != tt.wantBody {
+				t.Errorf("got %d %q, want %d %q", resp.StatusCode, got, tt.wantStatus, tt.wantBody)
+			}
+			if want, _ := NewRequest("GET", tt.path, "localhost", ""); req.Path != want.Path {
+				t.Errorf("the request's Path changed to %q", req.Path)
+			}
+		})
+	}
+}
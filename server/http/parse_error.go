@@ -0,0 +1,59 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of ParseError. Use errors.Is to check for them, e.g errors.Is(err, ErrMissingHost).
+// The limit errors (ErrRequestLineTooLong and friends) are kinds of ParseError too.
+var (
+	ErrBadRequestLine      = errors.New("malformed request line")
+	ErrBadStatusLine       = errors.New("malformed status line")
+	ErrBadTarget           = errors.New("malformed request target")
+	ErrUnsupportedVersion  = errors.New("unsupported HTTP version")
+	ErrBadHeader           = errors.New("malformed header")
+	ErrObsFold             = errors.New("obsolete line folding")
+	ErrMissingHost         = errors.New("missing Host header")
+	ErrBadContentLength    = errors.New("malformed Content-Length")
+	ErrBadTransferEncoding = errors.New("unsupported Transfer-Encoding")
+	ErrBadChunk            = errors.New("malformed chunked encoding")
+)
+
+// maxErrorInput is the most of the offending input a ParseError holds on to.
+const maxErrorInput = 128
+
+// ParseError is returned when a request or response is malformed or too large; it says what's wrong, and where.
+// Errors that aren't a *ParseError come from the underlying reader: e.g a network timeout,
+// or io.ErrUnexpectedEOF if the peer hung up halfway through the message.
+type ParseError struct {
+	Kind   error  // what went wrong; one of the Err* variables, e.g ErrBadHeader. errors.Is compares against it.
+	Line   int    // line the problem is on, counting the start line as 1. Lines inside body data aren't counted.
+	Offset int64  // byte offset of the start of that line from the start of the message; or, in the body, where reading stopped
+	Input  string // the offending input, e.g the malformed header line; cut off after 128 bytes. May be empty.
+	Reason string // a human-readable explanation, e.g "should be of form 'key: value'". May be empty.
+}
+
+func (e *ParseError) Error() string {
+	msg := fmt.Sprintf("%v at line %d (byte %d)", e.Kind, e.Line, e.Offset)
+	if e.Line == 0 { // in the body
+		msg = fmt.Sprintf("%v at byte %d", e.Kind, e.Offset)
+	}
+	if e.Input != "" {
+		msg += fmt.Sprintf(": %q", e.Input)
+	}
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg
+}
+
+func (e *ParseError) Unwrap() error { return e.Kind }
+
+// truncate cuts s down to maxErrorInput bytes.
+func truncate(s string) string {
+	if len(s) > maxErrorInput {
+		return s[:maxErrorInput] + "..."
+	}
+	return s
+}
@@ -0,0 +1,53 @@
+package http
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseErrors(t *testing.T) {
+	for name, tt := range map[string]struct {
+		input    string
+		response bool // parse as a response, rather than a request
+		wantKind error
+		wantLine int
+		wantOff  int64
+	}{
+		"one field":             {input: "GET\r\n\r\n", wantKind: ErrBadRequestLine, wantLine: 1},
+		"two fields":            {input: "GET /\r\nHost: a\r\n\r\n", wantKind: ErrBadRequestLine, wantLine: 1},
+		"bad target":            {input: "GET a/b HTTP/1.1\r\nHost: a\r\n\r\n", wantKind: ErrBadTarget, wantLine: 1},
+		"bad version":           {input: "GET / HTTP/2.0\r\nHost: a\r\n\r\n", wantKind: ErrUnsupportedVersion, wantLine: 1},
+		"not HTTP":              {input: "GET / FTP\r\nHost: a\r\n\r\n", wantKind: ErrBadRequestLine, wantLine: 1},
+		"bad header":            {input: "GET / HTTP/1.1\r\nHost: a\r\nnocolon\r\n\r\n", wantKind: ErrBadHeader, wantLine: 3, wantOff: 25},
+		"empty header key":      {input: "GET / HTTP/1.1\r\n: value\r\n\r\n", wantKind: ErrBadHeader, wantLine: 2, wantOff: 16},
+		"missing host":          {input: "GET / HTTP/1.1\r\nAccept: */*\r\n\r\n", wantKind: ErrMissingHost, wantLine: 3, wantOff: 29},
+		"bad content-length":    {input: "GET / HTTP/1.1\r\nHost: a\r\nContent-Length: -1\r\n\r\n", wantKind: ErrBadContentLength, wantLine: 4, wantOff: 45},
+		"signed content-length": {input: "GET / HTTP/1.1\r\nHost: a\r\nContent-Length: +3\r\n\r\nabc", wantKind: ErrBadContentLength, wantLine: 4, wantOff: 45},
+		"negative zero length":  {input: "GET / HTTP/1.1\r\nHost: a\r\nContent-Length: -0\r\n\r\n", wantKind: ErrBadContentLength, wantLine: 4, wantOff: 45},
+		"bad TE":                {input: "GET / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: gzip\r\n\r\n", wantKind: ErrBadTransferEncoding, wantLine: 4, wantOff: 50},
+		"bad chunk":             {input: "GET / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\n", wantKind: ErrBadChunk, wantLine: 5, wantOff: 55},
+		"status: one field":     {input: "HTTP/1.1\r\n\r\n", response: true, wantKind: ErrBadStatusLine, wantLine: 1},
+		"status: not int":       {input: "HTTP/1.1 OK 200\r\n\r\n", response: true, wantKind: ErrBadStatusLine, wantLine: 1},
+		"status: range":         {input: "HTTP/1.1 600 Weird\r\n\r\n", response: true, wantKind: ErrBadStatusLine, wantLine: 1},
+		"status: version":       {input: "HTTP/3 200 OK\r\n\r\n", response: true, wantKind: ErrUnsupportedVersion, wantLine: 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var err error
+			if tt.response {
+				_, err = ParseResponse(tt.input)
+			} else {
+				_, err = ParseRequest(tt.input)
+			}
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("got error %v (%T), want a *ParseError", err, err)
+			}
+			if !errors.Is(err, tt.wantKind) {
+				t.Errorf("got kind %v, want %v", perr.Kind, tt.wantKind)
+			}
+			if perr.Line != tt.wantLine || perr.Offset != tt.wantOff {
+				t.Errorf("got line %d, offset %d; want line %d, offset %d", perr.Line, perr.Offset, tt.wantLine, tt.wantOff)
+			}
+		})
+	}
+}
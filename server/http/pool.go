@@ -0,0 +1,223 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"time"
+)
+
+// The Client keeps connections open after a response is done with, and reuses them for the next request to the same
+// scheme, host and port, saving a TCP (and maybe TLS) handshake each time. A connection goes back to the pool only if
+// both sides are willing to keep it open and the response body was read to the end; see Response.Body.
+//
+// While a connection is idle, a goroutine waits on a read from it: the server shouldn't send anything, so if the
+// read returns, the server has hung up (or sent something we can't make sense of), and the connection is closed
+// there and then rather than when it's next picked up. A connection that's idle for longer than IdleConnTimeout
+// is closed by a timer, so it doesn't stay open just because there's never another request to its host.
+
+// DefaultMaxIdleConnsPerHost is the most idle connections the Client keeps per host, unless told otherwise.
+const DefaultMaxIdleConnsPerHost = 2
+
+// connKey identifies the connections that can be used for a request: the same scheme, host and port.
+type connKey struct {
+	scheme string // "http" or "https"
+	addr   string // host:port, lowercased
+}
+
+// persistConn is a connection the Client might use for more than one request.
+type persistConn struct {
+	key    connKey
+	conn   net.Conn
+	br     *bufio.Reader
+	bw     *bufio.Writer
+	reused bool      // whether it's carried a request before this one
+	idleAt time.Time // when it went back in the pool
+
+	idleTimer *time.Timer // closes it after IdleConnTimeout, while idle
+	idleRead  chan error  // the result of the read the idle watcher was waiting on; see alive
+	written   int64       // bytes written to conn, so we can tell if any of a request made it out; see shouldRetry
+}
+
+// Write writes to the connection, counting the bytes; bw writes through it.
+func (pc *persistConn) Write(p []byte) (int, error) {
+	n, err := pc.conn.Write(p)
+	pc.written += int64(n)
+	return n, err
+}
+
+// getConn returns a connection for key: an idle one from the pool if there's one that's still good,
+// or a new one, waiting for one of the others to be done first if there are already MaxConnsPerHost.
+func (c *Client) getConn(ctx context.Context, key connKey) (*persistConn, error) {
+	for {
+		c.mu.Lock()
+		if pc := c.popIdle(key); pc != nil {
+			c.mu.Unlock()
+			if pc.alive() {
+				pc.reused = true
+				return pc, nil
+			}
+			c.closeConn(pc)
+			continue
+		}
+		if c.MaxConnsPerHost <= 0 || c.numConns[key] < c.MaxConnsPerHost {
+			if c.numConns == nil {
+				c.numConns = make(map[connKey]int)
+			}
+			c.numConns[key]++
+			c.mu.Unlock()
+			pc, err := c.dialConn(ctx, key)
+			if err != nil {
+				c.forget(key)
+			}
+			return pc, err
+		}
+		// too many connections to this host already; wait for one to come back to the pool, or be closed.
+		if c.connFreed == nil {
+			c.connFreed = make(map[connKey]chan struct{})
+		}
+		freed, ok := c.connFreed[key]
+		if !ok {
+			freed = make(chan struct{})
+			c.connFreed[key] = freed
+		}
+		c.mu.Unlock()
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// popIdle takes the most recently used idle connection for key out of the pool, closing any that have been idle
+// for longer than IdleConnTimeout along the way, in case their timers haven't gone off yet. It returns nil if
+// there's none. c.mu must be held.
+func (c *Client) popIdle(key connKey) *persistConn {
+	for conns := c.idle[key]; len(conns) > 0; conns = c.idle[key] {
+		pc := conns[len(conns)-1]
+		c.idle[key] = conns[:len(conns)-1]
+		if pc.idleTimer != nil {
+			pc.idleTimer.Stop()
+		}
+		if c.IdleConnTimeout > 0 && time.Since(pc.idleAt) > c.IdleConnTimeout {
+			go c.closeConn(pc) // it takes the lock.
+			continue
+		}
+		return pc
+	}
+	return nil
+}
+
+// dialConn opens a new connection for key.
+func (c *Client) dialConn(ctx context.Context, key connKey) (*persistConn, error) {
+	conn, err := c.dial(ctx, key.addr, key.scheme == "https")
+	if err != nil {
+		return nil, err
+	}
+	pc := &persistConn{key: key, conn: conn, br: bufio.NewReader(conn)}
+	pc.bw = bufio.NewWriter(pc)
+	return pc, nil
+}
+
+// putIdle returns pc to the pool for the next request, or closes it if the pool's full.
+func (c *Client) putIdle(pc *persistConn) {
+	max := c.MaxIdleConnsPerHost
+	if max == 0 {
+		max = DefaultMaxIdleConnsPerHost
+	}
+	c.mu.Lock()
+	if len(c.idle[pc.key]) >= max { // including max < 0, which keeps none.
+		c.mu.Unlock()
+		c.closeConn(pc)
+		return
+	}
+	if c.idle == nil {
+		c.idle = make(map[connKey][]*persistConn)
+	}
+	pc.idleAt = time.Now()
+	pc.idleRead = make(chan error, 1)
+	if c.IdleConnTimeout > 0 {
+		pc.idleTimer = time.AfterFunc(c.IdleConnTimeout, func() { c.closeIdle(pc) })
+	}
+	c.idle[pc.key] = append(c.idle[pc.key], pc)
+	c.wakeLocked(pc.key)
+	c.mu.Unlock()
+	go func() {
+		_, err := pc.br.Peek(1)
+		c.closeIdle(pc) // unless it's been picked up for a request, and alive interrupted the read.
+		pc.idleRead <- err
+	}()
+}
+
+// closeIdle closes pc if it's still in the pool, because its server hung up or it's been idle for too long.
+func (c *Client) closeIdle(pc *persistConn) {
+	c.mu.Lock()
+	conns := c.idle[pc.key]
+	for i := range conns {
+		if conns[i] == pc {
+			c.idle[pc.key] = append(conns[:i:i], conns[i+1:]...)
+			if pc.idleTimer != nil {
+				pc.idleTimer.Stop()
+			}
+			c.mu.Unlock()
+			c.closeConn(pc)
+			return
+		}
+	}
+	c.mu.Unlock()
+}
+
+// closeConn closes pc for good, making room for another connection to its host.
+func (c *Client) closeConn(pc *persistConn) {
+	pc.conn.Close()
+	c.forget(pc.key)
+}
+
+// forget stops counting a connection to key, which has been closed or failed to open.
+func (c *Client) forget(key connKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.numConns[key]--; c.numConns[key] <= 0 {
+		delete(c.numConns, key)
+	}
+	c.wakeLocked(key)
+}
+
+// wakeLocked wakes up any requests waiting on a connection to key. c.mu must be held.
+func (c *Client) wakeLocked(key connKey) {
+	if freed, ok := c.connFreed[key]; ok {
+		close(freed)
+		delete(c.connFreed, key)
+	}
+}
+
+// CloseIdleConnections closes the connections in the pool. It doesn't touch connections that are in use.
+func (c *Client) CloseIdleConnections() {
+	c.mu.Lock()
+	idle := c.idle
+	c.idle = nil
+	c.mu.Unlock()
+	for _, conns := range idle {
+		for _, pc := range conns {
+			if pc.idleTimer != nil {
+				pc.idleTimer.Stop()
+			}
+			c.closeConn(pc)
+		}
+	}
+}
+
+// alive reports whether a connection just taken out of the pool is still usable. It interrupts the idle watcher's
+// read, which should time out: if it returned anything else, the server's hung up, or sent something it shouldn't
+// have, and the connection's no good.
+func (pc *persistConn) alive() bool {
+	pc.conn.SetReadDeadline(aLongTimeAgo)
+	err := <-pc.idleRead
+	pc.conn.SetReadDeadline(time.Time{})
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout() && pc.br.Buffered() == 0
+}
+
+// aLongTimeAgo is a read deadline in the past, which makes a blocked read return at once.
+var aLongTimeAgo = time.Unix(1, 0)
@@ -0,0 +1,294 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Range requests (RFC 9110 section 14) ask for parts of a representation rather than the whole thing,
+// e.g to resume a download, or to seek in a video:
+//
+//	Range: bytes=0-499       the first 500 bytes
+//	Range: bytes=500-        everything from byte 500 on
+//	Range: bytes=-500        the last 500 bytes
+//	Range: bytes=0-0,-1      the first and last bytes
+//
+// A single range comes back as a 206 Partial Content with a Content-Range saying which bytes they are;
+// several come back as a multipart/byteranges body, one part per range, each with its own Content-Range.
+// If none of the ranges overlap the content at all, the answer is a 416 Range Not Satisfiable.
+// A Range header we can't make sense of is ignored, as RFC 9110 says, and the whole content is sent.
+
+var (
+	// ErrInvalidRange is returned by ParseRange for a malformed Range header, or one in a unit other than bytes.
+	ErrInvalidRange = errors.New("http: invalid range")
+	// ErrUnsatisfiableRange is returned by ParseRange if none of the ranges overlap the content.
+	ErrUnsatisfiableRange = errors.New("http: unsatisfiable range")
+)
+
+// ByteRange is a range of bytes of some content, e.g {Start: 10, Length: 5} is bytes 10 through 14.
+type ByteRange struct {
+	Start, Length int64
+}
+
+// ContentRange formats the range for the Content-Range header of content of the given size, e.g "bytes 10-14/100".
+func (br ByteRange) ContentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", br.Start, br.Start+br.Length-1, size)
+}
+
+// ParseRange parses a Range header for content of the given size, returning the ranges that overlap it,
+// clipped to fit, in the order they were asked for. Ranges that don't overlap the content are dropped;
+// if that's all of them, the error is ErrUnsatisfiableRange. A malformed header is ErrInvalidRange.
+func ParseRange(header string, size int64) ([]ByteRange, error) {
+	unit, spec, ok := strings.Cut(header, "=")
+	if !ok || !strings.EqualFold(strings.TrimSpace(unit), "bytes") {
+		return nil, ErrInvalidRange
+	}
+	var ranges []ByteRange
+	var seen bool // whether there was any range at all, satisfiable or not.
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue // empty list elements are allowed; see RFC 9110 section 5.6.1.
+		}
+		first, last, ok := strings.Cut(part, "-")
+		if !ok {
+			return nil, ErrInvalidRange
+		}
+		first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+		seen = true
+		if first == "" {
+			// a suffix: the last n bytes.
+			n, err := parseRangeInt(last)
+			if err != nil {
+				return nil, ErrInvalidRange
+			}
+			if n == 0 || size == 0 {
+				continue // unsatisfiable.
+			}
+			if n > size {
+				n = size
+			}
+			ranges = append(ranges, ByteRange{size - n, n})
+			continue
+		}
+		start, err := parseRangeInt(first)
+		if err != nil {
+			return nil, ErrInvalidRange
+		}
+		end := size - 1
+		if last != "" {
+			if end, err = parseRangeInt(last); err != nil || end < start {
+				return nil, ErrInvalidRange
+			}
+			if end >= size {
+				end = size - 1
+			}
+		}
+		if start >= size {
+			continue // unsatisfiable.
+		}
+		ranges = append(ranges, ByteRange{start, end - start + 1})
+	}
+	switch {
+	case !seen:
+		return nil, ErrInvalidRange
+	case len(ranges) == 0:
+		return nil, ErrUnsatisfiableRange
+	}
+	return ranges, nil
+}
+
+// parseRangeInt parses a non-negative decimal number, digits only.
+func parseRangeInt(s string) (int64, error) {
+	if s == "" || strings.TrimLeft(s, "0123456789") != "" {
+		return 0, ErrInvalidRange
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// ServeContent responds to r with content, honoring its Range header for GET and HEAD requests.
+// Content-Type comes from the extension of name, or, if that's unknown, from sniffing the content;
+// set it on the response afterwards to override it. If modtime isn't zero, it's sent as Last-Modified, along with
+// an ETag made from it and the size, so the Server can answer conditional requests; the Range is only honored
+// if the If-Range header, if any, matches one of those.
+//
+// The ETag is a strong one, for content as is. Compress leaves partial responses alone, but if it compresses a
+// whole one, the bytes sent are no longer the ones the ETag stands for, and ranges asked for with it in If-Range
+// are of different bytes again. So don't compress what ServeContent serves, or make the ETag weak on the way out,
+// e.g with resp.Headers.Set("ETag", "W/"+resp.Headers.Get("ETag")), which turns If-Range off for it.
+//
+// The response body reads from content as it's sent, so content must stay valid until then;
+// if it's an io.Closer, it's closed once the body is done with, or straight away if there's no body.
+func ServeContent(r *Request, name string, modtime time.Time, content io.ReadSeeker) *Response {
+	closeContent := func() {
+		if c, ok := content.(io.Closer); ok {
+			c.Close()
+		}
+	}
+	size, err := content.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = content.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		closeContent()
+		return errorResponse(500)
+	}
+
+	resp := &Response{StatusCode: 200}
+	ctype, err := contentType(name, content)
+	if err != nil {
+		closeContent()
+		return errorResponse(500)
+	}
+	resp.Headers.Set("Content-Type", ctype)
+	resp.Headers.Set("Accept-Ranges", "bytes")
+	var etag string
+	if modtime.IsZero() || modtime.Unix() == 0 {
+		modtime = time.Time{}
+	} else {
+		etag = contentETag(modtime, size)
+		resp.Headers.Set("ETag", etag)
+		resp.Headers.Set("Last-Modified", modtime.UTC().Format(TimeFormat))
+	}
+
+	var ranges []ByteRange
+	if rh := r.Headers.Get("Range"); rh != "" && (r.Method == "GET" || r.Method == "HEAD") && ifRangeMatches(r, etag, modtime) {
+		ranges, err = ParseRange(rh, size)
+		switch {
+		case err == ErrUnsatisfiableRange:
+			closeContent()
+			resp := errorResponse(416)
+			return resp.WithHeader("Content-Range", fmt.Sprintf("bytes */%d", size))
+		case err != nil:
+			ranges = nil // a malformed Range is ignored.
+		case sumRanges(ranges) > size:
+			ranges = nil // overlapping ranges would add up to more than the whole; just send that.
+		}
+	}
+
+	var body io.Reader = content
+	stop := func() {} // stops the body early
+	length := size
+	switch {
+	case len(ranges) == 1:
+		rng := ranges[0]
+		if _, err := content.Seek(rng.Start, io.SeekStart); err != nil {
+			closeContent()
+			return errorResponse(500)
+		}
+		resp.StatusCode = 206
+		resp.Headers.Set("Content-Range", rng.ContentRange(size))
+		body, length = io.LimitReader(content, rng.Length), rng.Length
+	case len(ranges) > 1:
+		resp.StatusCode = 206
+		boundary := multipart.NewWriter(nil).Boundary() // a random one.
+		resp.Headers.Set("Content-Type", "multipart/byteranges; boundary="+boundary)
+		length = multipartLength(ranges, size, ctype, boundary)
+		if r.Method != "HEAD" {
+			pr := multipartRanges(content, ranges, size, ctype, boundary)
+			body, stop = pr, func() { pr.Close() }
+		}
+	}
+	resp.Headers.Set("Content-Length", strconv.FormatInt(length, 10))
+	if r.Method == "HEAD" {
+		closeContent()
+		return resp
+	}
+	resp.Body = readCloser{body, closerFunc(func() error {
+		stop()
+		closeContent()
+		return nil
+	})}
+	resp.ContentLength = length
+	return resp
+}
+
+// contentETag returns a strong ETag for content identified by its modification time and size, as files are.
+func contentETag(modtime time.Time, size int64) string {
+	return `"` + strconv.FormatInt(modtime.UnixNano(), 36) + "-" + strconv.FormatInt(size, 36) + `"`
+}
+
+// sniffLen is how much of the content DetectContentType looks at.
+const sniffLen = 512
+
+// contentType picks the Content-Type for content by the extension of name, or, failing that, by sniffing
+// the start of content, which is then rewound.
+func contentType(name string, content io.ReadSeeker) (string, error) {
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		return ctype, nil
+	}
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(content, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// sumRanges returns the total length of ranges.
+func sumRanges(ranges []ByteRange) (n int64) {
+	for _, rng := range ranges {
+		n += rng.Length
+	}
+	return n
+}
+
+// rangePartHeader is the header of the part of a multipart/byteranges body for rng.
+func rangePartHeader(rng ByteRange, size int64, ctype string) textproto.MIMEHeader {
+	return textproto.MIMEHeader{"Content-Type": {ctype}, "Content-Range": {rng.ContentRange(size)}}
+}
+
+// multipartLength returns the length of the multipart/byteranges body multipartRanges would write: the length of
+// the framing, which we get by writing it without the content, plus the length of the content.
+func multipartLength(ranges []ByteRange, size int64, ctype, boundary string) int64 {
+	cw := &countingWriter{w: io.Discard}
+	mw := multipart.NewWriter(cw)
+	mw.SetBoundary(boundary)
+	for _, rng := range ranges {
+		mw.CreatePart(rangePartHeader(rng, size, ctype))
+	}
+	mw.Close()
+	return cw.n + sumRanges(ranges)
+}
+
+// multipartRanges returns a multipart/byteranges body for the given ranges of content, one part per range.
+// It streams: the parts are written into a pipe as it's read. Closing it stops the writing.
+func multipartRanges(content io.ReadSeeker, ranges []ByteRange, size int64, ctype, boundary string) *io.PipeReader {
+	pr, pw := io.Pipe()
+	go func() {
+		mw := multipart.NewWriter(pw)
+		mw.SetBoundary(boundary)
+		for _, rng := range ranges {
+			part, err := mw.CreatePart(rangePartHeader(rng, size, ctype))
+			if err == nil {
+				_, err = content.Seek(rng.Start, io.SeekStart)
+			}
+			if err == nil {
+				_, err = io.CopyN(part, content, rng.Length)
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.CloseWithError(mw.Close())
+	}()
+	return pr
+}
+
+// closerFunc lets an ordinary function be used as an io.Closer.
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRange(t *testing.T) {
+	for name, tt := range map[string]struct {
+		header  string
+		size    int64
+		want    []ByteRange
+		wantErr error
+	}{
+		"first bytes":        {"bytes=0-4", 10, []ByteRange{{0, 5}}, nil},
+		"open ended":         {"bytes=6-", 10, []ByteRange{{6, 4}}, nil},
+		"suffix":             {"bytes=-3", 10, []ByteRange{{7, 3}}, nil},
+		"suffix too long":    {"bytes=-30", 10, []ByteRange{{0, 10}}, nil},
+		"end past size":      {"bytes=8-100", 10, []ByteRange{{8, 2}}, nil},
+		"several":            {"bytes=0-0, -1", 10, []ByteRange{{0, 1}, {9, 1}}, nil},
+		"empty elements":     {"bytes=,0-1,,", 10, []ByteRange{{0, 2}}, nil},
+		"case insensitive":   {"Bytes=0-1", 10, []ByteRange{{0, 2}}, nil},
+		"drop unsatisfiable": {"bytes=20-30,0-1", 10, []ByteRange{{0, 2}}, nil},
+		"unsatisfiable":      {"bytes=10-", 10, nil, ErrUnsatisfiableRange},
+		"zero suffix":        {"bytes=-0", 10, nil, ErrUnsatisfiableRange},
+		"empty content":      {"bytes=0-", 0, nil, ErrUnsatisfiableRange},
+		"other unit":         {"items=0-1", 10, nil, ErrInvalidRange},
+		"no dash":            {"bytes=5", 10, nil, ErrInvalidRange},
+		"backwards":          {"bytes=5-4", 10, nil, ErrInvalidRange},
+		"negative":           {"bytes=--1", 10, nil, ErrInvalidRange},
+		"sign":               {"bytes=+1-2", 10, nil, ErrInvalidRange},
+		"no ranges":          {"bytes=", 10, nil, ErrInvalidRange},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseRange(tt.header, tt.size)
+			if err != tt.wantErr {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServeContent(t *testing.T) {
+	const content = "0123456789"
+	modTime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	for name, tt := range map[string]struct {
+		method, rangeHeader string
+		wantStatus          int
+		wantContentRange    string
+		wantLength          string
+		wantBody            string
+		wantParts           []string // for multipart/byteranges: Content-Range and body of each part
+	}{
+		"whole":          {"GET", "", 200, "", "10", content, nil},
+		"single":         {"GET", "bytes=2-4", 206, "bytes 2-4/10", "3", "234", nil},
+		"suffix":         {"GET", "bytes=-2", 206, "bytes 8-9/10", "2", "89", nil},
+		"unsatisfiable":  {"GET", "bytes=10-", 416, "bytes */10", "", "Requested Range Not Satisfiable", nil},
+		"malformed":      {"GET", "bytes=x-y", 200, "", "10", content, nil},
+		"too much":       {"GET", "bytes=0-9,0-9", 200, "", "10", content, nil},
+		"ignored on PUT": {"PUT", "bytes=0-1", 200, "", "10", content, nil},
+		"HEAD":           {"HEAD", "bytes=2-4", 206, "bytes 2-4/10", "3", "", nil},
+		"multiple":       {"GET", "bytes=0-1,7-", 206, "", "", "", []string{"bytes 0-1/10 01", "bytes 7-9/10 789"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, _ := NewRequest(tt.method, "/digits.txt", "localhost", "")
+			if tt.rangeHeader != "" {
+				req.Headers.Set("Range", tt.rangeHeader)
+			}
+			resp := ServeContent(req, "digits.txt", modTime, bytes.NewReader([]byte(content)))
+			body := bodyOf(t, resp)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := resp.Headers.Get("Content-Range"); got != tt.wantContentRange {
+				t.Errorf("got Content-Range %q, want %q", got, tt.wantContentRange)
+			}
+			if tt.wantParts == nil {
+				if tt.wantLength != "" && resp.Headers.Get("Content-Length") != tt.wantLength {
+					t.Errorf("got Content-Length %q, want %q", resp.Headers.Get("Content-Length"), tt.wantLength)
+				}
+				if body != tt.wantBody {
+					t.Errorf("got body %q, want %q", body, tt.wantBody)
+				}
+				return
+			}
+			if got, want := resp.Headers.Get("Content-Length"), strconv.Itoa(len(body)); got != want {
+				t.Errorf("got Content-Length %s, but the body is %s bytes", got, want)
+			}
+			mt, params, err := mime.ParseMediaType(resp.Headers.Get("Content-Type"))
+			if err != nil || mt != "multipart/byteranges" {
+				t.Fatalf("got Content-Type %q, want multipart/byteranges", resp.Headers.Get("Content-Type"))
+			}
+			mr := multipart.NewReader(strings.NewReader(body), params["boundary"])
+			var parts []string
+			for {
+				p, err := mr.NextPart()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ct := p.Header.Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+					t.Errorf("got part Content-Type %q", ct)
+				}
+				b, _ := io.ReadAll(p)
+				parts = append(parts, p.Header.Get("Content-Range")+" "+string(b))
+			}
+			if !reflect.DeepEqual(parts, tt.wantParts) {
+				t.Errorf("got parts %q, want %q", parts, tt.wantParts)
+			}
+		})
+	}
+}
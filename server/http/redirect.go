@@ -0,0 +1,111 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+)
+
+// The Client follows redirects (RFC 9110 section 15.4) the way browsers and net/http do:
+//
+//	301 Moved Permanently, 302 Found   POST becomes GET, without the body; other methods are kept, body and all
+//	303 See Other                      everything but HEAD becomes GET, without the body
+//	307 Temporary Redirect,
+//	308 Permanent Redirect             the method and body are kept as they were
+//
+// A body can only be sent again if it's in memory (see SetBody); if it isn't, the redirect response is returned as is.
+// When a redirect leads to a different host (or port, or scheme), the credentials meant for the first host are
+// dropped: the Authorization, Proxy-Authorization and Cookie headers aren't sent on.
+
+// DefaultMaxRedirects is the most redirects the Client follows for a single request, unless CheckRedirect says otherwise.
+const DefaultMaxRedirects = 10
+
+// ErrUseLastResponse can be returned by Client.CheckRedirect to stop following redirects
+// and have Do return the redirect response, body unread.
+var ErrUseLastResponse = errors.New("http: use last response")
+
+// sensitiveHeaders are the headers that don't follow a redirect to another host.
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+func (c *Client) checkRedirect(req *Request, via []*Request) error {
+	if c.CheckRedirect != nil {
+		return c.CheckRedirect(req, via)
+	}
+	if len(via) > DefaultMaxRedirects {
+		return fmt.Errorf("stopped after %d redirects", DefaultMaxRedirects)
+	}
+	return nil
+}
+
+// redirectRequest builds the request that follows the redirect resp, the response to req.
+// It returns nil if resp isn't a redirect, or isn't one we can follow; it's an error if the Location is malformed.
+func redirectRequest(req *Request, resp *Response) (*Request, error) {
+	switch resp.StatusCode {
+	case 301, 302, 303, 307, 308:
+	default:
+		return nil, nil
+	}
+	loc := resp.Headers.Get("Location")
+	if loc == "" {
+		return nil, nil // nowhere to go; it's up to the caller what to make of it.
+	}
+	key, authority, err := requestAddr(req)
+	if err != nil {
+		return nil, err
+	}
+	base := url.URL{Scheme: key.scheme, Host: authority, Path: req.Path, RawQuery: req.RawQuery}
+	u, err := base.Parse(loc)
+	if err != nil {
+		return nil, fmt.Errorf("http: redirect to malformed Location %q: %w", loc, err)
+	}
+
+	next := *req // shallow copy; the body is shared
+	next.Scheme, next.Authority = u.Scheme, u.Host
+	next.Path, next.RawQuery = u.Path, u.RawQuery
+	if next.Path == "" {
+		next.Path = "/"
+	}
+	next.Headers = req.Headers.Clone()
+	next.Headers.Del("Host") // it's req's; the Client fills in next's from the Authority.
+	next.params = nil
+	switch {
+	case resp.StatusCode == 303 && req.Method != "HEAD", (resp.StatusCode == 301 || resp.StatusCode == 302) && req.Method == "POST":
+		next.Method, next.Body, next.ContentLength = "GET", nil, 0
+		for _, key := range []string{"Content-Length", "Content-Type", "Transfer-Encoding"} {
+			next.Headers.Del(key)
+		}
+	default:
+		if _, ok := req.Body.(*memBody); !ok && req.Body != nil {
+			return nil, nil // the body's gone; we can't send it again.
+		}
+	}
+	nextKey, _, err := requestAddr(&next)
+	if err != nil {
+		return nil, err
+	}
+	if nextKey != key {
+		for _, name := range sensitiveHeaders {
+			next.Headers.Del(name)
+		}
+	}
+	return &next, nil
+}
+
+// maxRedirectDrain is the most of a redirect response body the Client reads to be able to reuse the connection.
+const maxRedirectDrain = 2 << 10
+
+// discardBody reads and closes a body we don't care about, e.g a redirect's.
+// If it's short, as they usually are, reading it to the end lets the connection be reused.
+func discardBody(resp *Response) {
+	if resp.Body != nil {
+		io.CopyN(io.Discard, resp.Body, maxRedirectDrain)
+	}
+	closeBody(resp)
+}
+
+func closeBody(resp *Response) {
+	if resp.Body != nil {
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,100 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestClientRedirect(t *testing.T) {
+	// other is another host, as far as the client can tell: same IP, different port.
+	other := startServer(t, &Server{Handler: HandlerFunc(echoRequest)})
+	mux := NewMux()
+	for _, code := range []int{301, 302, 303, 307, 308} {
+		code := code
+		for _, method := range []string{"GET", "POST", "PUT", "HEAD"} {
+			mux.HandleFunc(method, fmt.Sprintf("/%d", code), func(r *Request) *Response {
+				resp, _ := NewResponse(code, "")
+				return resp.WithHeader("Location", "/target?from="+r.Path[1:])
+			})
+		}
+	}
+	mux.HandleFunc("GET", "/other", func(r *Request) *Response {
+		resp, _ := NewResponse(302, "")
+		return resp.WithHeader("Location", "http://"+other+"/there")
+	})
+	mux.HandleFunc("GET", "/loop", func(r *Request) *Response {
+		resp, _ := NewResponse(302, "")
+		return resp.WithHeader("Location", "/loop")
+	})
+	mux.HandleFunc("GET", "/nowhere", func(r *Request) *Response {
+		resp, _ := NewResponse(302, "no Location")
+		return resp
+	})
+	for _, method := range []string{"GET", "POST", "PUT", "HEAD"} {
+		mux.HandleFunc(method, "/target", echoRequest)
+	}
+	addr := startServer(t, &Server{Handler: mux})
+
+	for name, tt := range map[string]struct {
+		method, path, body string
+		check              func(req *Request, via []*Request) error
+		wantStatus         int
+		wantBody           string
+		wantErr            string
+	}{
+		"301 GET":         {method: "GET", path: "/301", wantStatus: 200, wantBody: "GET /target?from=301 auth=secret cookie=c=1: "},
+		"301 POST to GET": {method: "POST", path: "/301", body: "hi", wantStatus: 200, wantBody: "GET /target?from=301 auth=secret cookie=c=1: "},
+		"302 PUT kept":    {method: "PUT", path: "/302", body: "hi", wantStatus: 200, wantBody: "PUT /target?from=302 auth=secret cookie=c=1: hi"},
+		"303 PUT to GET":  {method: "PUT", path: "/303", body: "hi", wantStatus: 200, wantBody: "GET /target?from=303 auth=secret cookie=c=1: "},
+		"303 HEAD kept":   {method: "HEAD", path: "/303", wantStatus: 200},
+		"307 POST kept":   {method: "POST", path: "/307", body: "hi", wantStatus: 200, wantBody: "POST /target?from=307 auth=secret cookie=c=1: hi"},
+		"308 POST kept":   {method: "POST", path: "/308", body: "hi", wantStatus: 200, wantBody: "POST /target?from=308 auth=secret cookie=c=1: hi"},
+		"other host":      {method: "GET", path: "/other", wantStatus: 200, wantBody: "GET /there auth= cookie=: "},
+		"no Location":     {method: "GET", path: "/nowhere", wantStatus: 302, wantBody: "no Location"},
+		"too many":        {method: "GET", path: "/loop", wantErr: "stopped after 10 redirects"},
+		"vetoed": {
+			method: "GET", path: "/301",
+			check:   func(req *Request, via []*Request) error { return errors.New("no thanks") },
+			wantErr: "no thanks",
+		},
+		"use last response": {
+			method: "GET", path: "/301",
+			check:      func(req *Request, via []*Request) error { return ErrUseLastResponse },
+			wantStatus: 301, wantBody: "Moved Permanently",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, _ := NewRequest(tt.method, tt.path, addr, tt.body)
+			req.Headers.Set("Authorization", "secret")
+			req.Headers.Set("Cookie", "c=1")
+			client := &Client{CheckRedirect: tt.check}
+			resp, err := client.Do(context.Background(), req)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Do: got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+			if body := bodyOf(t, resp); resp.StatusCode != tt.wantStatus || body != tt.wantBody {
+				t.Errorf("got %d %q, want %d %q", resp.StatusCode, body, tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}
+
+// echoRequest answers with what the server got: the method, target, credentials and body.
+func echoRequest(r *Request) *Response {
+	body, err := r.BodyBytes()
+	if err != nil {
+		return nil
+	}
+	resp, _ := NewResponse(200, fmt.Sprintf("%s %s auth=%s cookie=%s: %s",
+		r.Method, r.RequestTarget(), r.Headers.Get("Authorization"), r.Headers.Get("Cookie"), body))
+	return resp
+}
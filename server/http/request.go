@@ -1,21 +1,45 @@
 package http
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
+	"net/url"
 	"strings"
 )
 
 // Request represents a HTTP 1.1 request.
 type Request struct {
-	Method  string
-	Path    string
-	Headers []Header
-	Body    string // e.b, <html><body><h1>Hello, World!</h1></body></html>
+	Method    string
+	Path      string // decoded path of the request-target, e.g "/a b" for "/a%20b?q=go"; "*" for "OPTIONS *"
+	RawQuery  string // still-encoded query of the request-target, without the '?'; e.g "q=go". See Query()
+	Scheme    string // only for absolute-form targets, e.g "http" for "GET http://example.com/ HTTP/1.1"
+	Authority string // only for absolute-form and authority-form targets, e.g "example.com:443" for "CONNECT example.com:443 HTTP/1.1"
+	Proto     string // "HTTP/1.0" or "HTTP/1.1"; if empty, WriteTo uses "HTTP/1.1"
+	Headers   Headers
+	// Body is the request body, e.b, <html><body><h1>Hello, World!</h1></body></html>; nil means there isn't one.
+	// On the server, it streams from the connection. See SetBody and BodyBytes for working with it in memory.
+	Body          io.ReadCloser
+	ContentLength int64   // length of Body in bytes; -1 if unknown, as is 0 with a non-nil Body.
+	Trailer       Headers // trailer fields; only sent or received with "Transfer-Encoding: chunked"
+
+	// Form holds the form fields of the body and then the query parameters; PostForm, only the body's.
+	// MultipartForm holds the fields and files of a multipart/form-data body. They're nil until ParseForm
+	// or ParseMultipartForm fills them in.
+	Form          url.Values
+	PostForm      url.Values
+	MultipartForm *multipart.Form
+
+	params map[string]string // path parameters matched by a Mux; see Param()
+	ctx    context.Context   // see Context() and WithContext()
+	forms  *formFiles        // multipart forms to clean up after the response; shared with copies, see ParseMultipartForm
+	srv    *Server           // the Server that read it, if any; Recover logs to its ErrorLog
 }
 
 // NewRequest Create New Request instance with the following arguments
+// path may include a query, e.g "/search?q=go"; it's split into Path and RawQuery.
 func NewRequest(method, path, host, body string) (*Request, error) {
 	switch {
 	case method == "":
@@ -27,26 +51,86 @@ func NewRequest(method, path, host, body string) (*Request, error) {
 	case host == "":
 		return nil, errors.New("missing required argument: host")
 	default:
-		headers := make([]Header, 2)
-		headers[0] = Header{Key: "Host", Value: host}
+		headers := Headers{{Key: "Host", Value: host}}
 		if body != "" {
-			headers = append(headers, Header{"Content-Length", fmt.Sprintf("%d", len(body))})
+			headers.Add("Content-Length", fmt.Sprintf("%d", len(body)))
 		}
-		return &Request{
+		r := &Request{
 			Method:  method,
-			Path:    path,
 			Headers: headers,
-			Body:    body,
-		}, nil
+		}
+		if err := r.parseOrigin(path); err != nil {
+			return nil, err
+		}
+		if body != "" {
+			r.SetBody([]byte(body))
+		}
+		return r, nil
+	}
+}
+
+// expectsContinue reports whether the client will wait for a 100 Continue before sending the body.
+// RFC 9110 section 10.1.1 says to ignore the expectation in HTTP/1.0 requests, since 1.0 has no 1xx responses.
+func (r *Request) expectsContinue() bool {
+	return r.Proto != "HTTP/1.0" && hasToken(r.Headers, "Expect", "100-continue")
+}
+
+// keepAlive reports whether the client wants to keep the connection open after this request.
+// HTTP/1.1 connections are persistent unless the client says "Connection: close";
+// HTTP/1.0 connections are closed unless the client says "Connection: keep-alive". See RFC 9112 section 9.3.
+//
+// A request with both a Transfer-Encoding and a Content-Length is framed by the former, but whatever's in front of us
+// might have gone by the latter, and so disagree about where the next request starts: RFC 9112 section 6.3 says to
+// close the connection after answering it.
+func (r *Request) keepAlive() bool {
+	switch {
+	case hasToken(r.Headers, "Connection", "close"):
+		return false
+	case r.Headers.Has("Transfer-Encoding") && r.Headers.Has("Content-Length"):
+		return false
+	case r.Proto == "HTTP/1.0":
+		return hasToken(r.Headers, "Connection", "keep-alive")
+	default:
+		return true
 	}
 }
 
+// Context returns the request's context. On the server, it's canceled once the server is done with the connection.
+// It's never nil; a request without one gets context.Background().
+func (r *Request) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// WithContext returns a shallow copy of r with its context changed to ctx.
+func (r *Request) WithContext(ctx context.Context) *Request {
+	if ctx == nil {
+		panic("nil context")
+	}
+	r2 := *r
+	r2.ctx = ctx
+	return &r2
+}
+
 func (r *Request) WithHeader(key, value string) *Request {
-	r.Headers = append(r.Headers, Header{AsTitle(key), value})
+	r.Headers.Add(key, value)
 	return r
 }
 
+// WriteTo writes the request to w as it'd go over the wire. Unless the body is in memory (see SetBody), it's used up and closed.
 func (r *Request) WriteTo(w io.Writer) (n int64, err error) {
+	if n, err = r.writeHeader(w); err != nil {
+		return n, err
+	}
+	// Write the body as-is: anything after it would be mistaken for the start of the next request.
+	m, err := writeBody(w, r.Body, r.Headers, &r.Trailer)
+	return n + m, err
+}
+
+// writeHeader writes the request line and headers, up to and including the empty line before the body.
+func (r *Request) writeHeader(w io.Writer) (n int64, err error) {
 	// write & count bytes written
 	// using small closures like this to cut down on repetition
 	printf := func(format string, args ...any) error {
@@ -56,17 +140,22 @@ func (r *Request) WriteTo(w io.Writer) (n int64, err error) {
 		return err
 	}
 
-	if err := printf("%s %s HTTP/1.1\r\n", r.Method, r.Path); err != nil {
+	proto := r.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+	if err := printf("%s %s %s\r\n", r.Method, r.RequestTarget(), proto); err != nil {
 		return n, err
 	}
 
 	for _, h := range r.Headers {
-		if err := printf("%s %s HTTP/1.1\r\n", h.Key, h.Value); err != nil {
+		if err := printf("%s: %s\r\n", h.Key, h.Value); err != nil {
 			return n, err
 		}
 	}
 
-	printf("\r\n")                 // Write the empty line that separates the headers from the body
-	err = printf("%s\r\n", r.Body) // Write the body and terminate with a newline
-	return n, err
+	if err := printf("\r\n"); err != nil { // Write the empty line that separates the headers from the body
+		return n, err
+	}
+	return n, nil
 }
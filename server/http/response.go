@@ -9,45 +9,89 @@ import (
 
 // Response represents a HTTP Response
 type Response struct {
-	StatusCode int // e.g 200
-	Headers    []Header
-	Body       string
+	StatusCode int    // e.g 200
+	Proto      string // "HTTP/1.0" or "HTTP/1.1"; if empty, WriteTo uses "HTTP/1.1"
+	Headers    Headers
+	// Body is the response body; nil means there isn't one.
+	// On the client, it streams from the connection. See SetBody and BodyBytes for working with it in memory.
+	Body          io.ReadCloser
+	ContentLength int64    // length of Body in bytes; -1 if unknown, as is 0 with a non-nil Body.
+	Trailer       Headers  // trailer fields; only sent or received with "Transfer-Encoding: chunked"
+	Request       *Request // the request this is a response to, if known; set by the Client, and by the Server before writing
+	Uncompressed  bool     // the Client decompressed the body, and removed the Content-Encoding and Content-Length to match
 }
 
 // NewResponse create new Response instance with the following arguments
+// If body is empty, the status text is used instead; responses that can't have a body (1xx, 204, 304) get none at all.
 func NewResponse(status int, body string) (*Response, error) {
 	switch {
 	case status < 100 || status > 599:
 		return nil, errors.New("invalid status code")
 	default:
+		resp := &Response{StatusCode: status}
+		if !statusHasBody(status) {
+			return resp, nil // not even the status text; see hasBody.
+		}
 		if body == "" {
 			body = http.StatusText(status)
 		}
-		headers := make([]Header, 1)
-		headers[0] = Header{
-			"Content-Length",
-			fmt.Sprintf("%d", len(body)),
-		}
-		return &Response{
-			StatusCode: status,
-			Headers:    headers,
-			Body:       body,
-		}, nil
+		resp.Headers = Headers{{"Content-Length", fmt.Sprintf("%d", len(body))}}
+		resp.SetBody([]byte(body))
+		return resp, nil
+	}
+}
+
+// hasBody reports whether the response can have a body at all. RFC 9112 section 6.3 says responses to HEAD requests,
+// 1xx (Informational), 204 (No Content) and 304 (Not Modified) responses never do, whatever the headers say.
+func (res *Response) hasBody() bool {
+	switch {
+	case res.Request != nil && res.Request.Method == "HEAD":
+		return false
+	default:
+		return statusHasBody(res.StatusCode)
+	}
+}
+
+// statusHasBody reports whether a response with the given status code can have a body: all but
+// 1xx (Informational), 204 (No Content) and 304 (Not Modified) can.
+func statusHasBody(status int) bool {
+	return status >= 200 && status != 204 && status != 304
+}
+
+// keepAlive reports whether the connection can carry another response after this one: the server didn't ask to close it,
+// and the body has a length or is chunked, so we can tell where it ends without waiting for the server to hang up.
+func (res *Response) keepAlive() bool {
+	switch {
+	case hasToken(res.Headers, "Connection", "close"):
+		return false
+	case res.hasBody() && !res.Headers.Has("Content-Length") && !isChunked(res.Headers):
+		return false
+	case res.Proto == "HTTP/1.0":
+		return hasToken(res.Headers, "Connection", "keep-alive")
+	default:
+		return true
 	}
 }
 
 func (res *Response) WithHeader(key, value string) *Response {
-	res.Headers = append(res.Headers, Header{AsTitle(key), value})
+	res.Headers.Add(key, value)
 	return res
 }
 
+// WriteTo writes the response to w as it'd go over the wire. Unless the body is in memory (see SetBody), it's used up and closed.
+// A response that can't have a body, e.g a 304, or the response to a HEAD Request, is written without one,
+// though its headers, Content-Length included, are written as they are.
 func (res *Response) WriteTo(w io.Writer) (n int64, err error) {
 	printf := func(format string, args ...any) error {
 		m, err := fmt.Fprintf(w, format, args...)
 		n += int64(m)
 		return err
 	}
-	if err := printf("HTTP/1.1 %d %s\r\n", res.StatusCode, http.StatusText(res.StatusCode)); err != nil {
+	proto := res.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+	if err := printf("%s %d %s\r\n", proto, res.StatusCode, http.StatusText(res.StatusCode)); err != nil {
 		return n, err
 	}
 	for _, h := range res.Headers {
@@ -56,8 +100,16 @@ func (res *Response) WriteTo(w io.Writer) (n int64, err error) {
 		}
 
 	}
-	if err := printf("\r\n%s\r\n", res.Body); err != nil {
+	if err := printf("\r\n"); err != nil {
 		return n, err
 	}
-	return n, nil
+	if !res.hasBody() {
+		if res.Body != nil {
+			res.Body.Close()
+		}
+		return n, nil
+	}
+	// Write the body as-is: on a persistent connection, anything after it would be mistaken for the next response.
+	m, err := writeBody(w, res.Body, res.Headers, &res.Trailer)
+	return n + m, err
 }
@@ -0,0 +1,343 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Handler responds to a HTTP request by building a Response.
+// A nil Response is treated as a 500 Internal Server Error.
+type Handler interface {
+	ServeHTTP(r *Request) *Response
+}
+
+// HandlerFunc lets an ordinary function be used as a Handler.
+type HandlerFunc func(r *Request) *Response
+
+func (f HandlerFunc) ServeHTTP(r *Request) *Response { return f(r) }
+
+// ErrServerClosed is returned by Serve and ListenAndServe after Close is called.
+var ErrServerClosed = errors.New("http: server closed")
+
+// Server serves HTTP/1.1 requests with a Handler, one goroutine per connection.
+// The zero value is NOT ready to use: Handler must be set.
+type Server struct {
+	Addr     string      // TCP address to listen on, e.g ":8080"; if empty, ":http" (port 80) is used.
+	Handler  Handler     // handler to invoke for every request
+	ErrorLog *log.Logger // logger for errors that can't be reported to the client; if nil, the log package's standard logger is used.
+	Parser   Parser      // limits on the requests we'll read; the zero value uses the defaults. See Parser.
+
+	// Timeouts, so a slow or idle client can't hold on to a connection (and its goroutine) forever.
+	// Each is applied with the connection's SetReadDeadline or SetWriteDeadline; zero means no timeout.
+	ReadHeaderTimeout time.Duration // how long a client has to send the request line and headers; if zero, ReadTimeout is used
+	ReadTimeout       time.Duration // how long a client has to send the whole request, body included
+	WriteTimeout      time.Duration // how long the handler and writing the response can take, from the end of the request headers
+	IdleTimeout       time.Duration // how long to wait for the next request on a kept-alive connection; if zero, ReadTimeout is used
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+}
+
+// ListenAndServe listens on the TCP address s.Addr and then calls Serve.
+func (s *Server) ListenAndServe() error {
+	addr := s.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l, serving each of them in a new goroutine.
+// It always returns a non-nil error and closes l; after Close, the error is ErrServerClosed.
+func (s *Server) Serve(l net.Listener) error {
+	if s.Handler == nil {
+		return errors.New("http: server has no handler")
+	}
+	if !s.trackListener(l, true) {
+		l.Close()
+		return ErrServerClosed
+	}
+	defer s.trackListener(l, false)
+	defer l.Close()
+
+	for {
+		// loop forever, accepting connections one at a time
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// Close immediately closes all listeners and connections. It doesn't wait for handlers to return.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	var err error
+	for l := range s.listeners {
+		if cerr := l.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	return err
+}
+
+// serveConn answers requests from conn until either side wants to close it, or the client hangs up.
+// Requests are answered one at a time, in order, so pipelined requests get their responses in the order they were sent.
+func (s *Server) serveConn(conn net.Conn) {
+	if !s.trackConn(conn, true) {
+		conn.Close()
+		return
+	}
+	defer s.trackConn(conn, false)
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel() // tells any handler still running that the client is gone.
+
+	br, bw := bufio.NewReader(conn), bufio.NewWriter(conn)
+	for first := true; ; first = false {
+		if !first && br.Buffered() == 0 {
+			// wait for the next request; the header timeout only starts once it does.
+			conn.SetReadDeadline(deadline(time.Now(), s.idleTimeout()))
+			if _, err := br.Peek(1); err != nil {
+				return // idle for too long, or the client hung up.
+			}
+		}
+		start := time.Now()
+		conn.SetReadDeadline(deadline(start, s.readHeaderTimeout()))
+		req, err := s.Parser.ReadRequest(br)
+		if err == io.EOF {
+			return // client hung up between requests; nothing to answer.
+		}
+		if isTimeout(err) {
+			return // too slow to be worth answering, or logging: it's what a slow-loris attack looks like.
+		}
+		conn.SetReadDeadline(deadline(start, s.ReadTimeout)) // for the body.
+		conn.SetWriteDeadline(deadline(time.Now(), s.WriteTimeout))
+		var resp *Response
+		var keepAlive bool
+		if err != nil {
+			// we can't trust anything after a malformed (or too large) request, so we answer and hang up.
+			s.logf("reading request from %s: %v", conn.RemoteAddr(), err)
+			resp, _ = NewResponse(statusForError(err), "")
+		} else if unknownExpectation(req) {
+			resp = errorResponse(417) // and hang up: the body might be on its way regardless.
+		} else {
+			req.ctx = ctx
+			req.forms = new(formFiles)
+			req.srv = s
+			if req.Body != nil && req.expectsContinue() {
+				req.Body = &continueReader{rc: req.Body, bw: bw}
+			}
+			reqBody := req.Body // the handler might swap it out.
+			if resp = s.Handler.ServeHTTP(req); resp != nil {
+				resp = applyPreconditions(req, resp)
+			}
+			if cr, ok := reqBody.(*continueReader); ok {
+				cr.stop() // whether or not we drain the body, no 100 Continue can follow the response.
+			}
+			if bodyTooLarge(reqBody) && (resp == nil || resp.StatusCode != 413) {
+				// a chunked body's length isn't known up front, so the handler's read is the first to find out it's
+				// too long; whatever it made of that, e.g a 500, the client should hear what the problem is.
+				if resp != nil && resp.Body != nil {
+					resp.Body.Close()
+				}
+				resp = errorResponse(413).WithHeader("Connection", "close") // the rest of the body is still to come.
+			}
+			// to get to the next request, we have to get past whatever the handler didn't read of this one's body.
+			// Unless the response closes the connection anyway: then someone may still be reading it, e.g a handler
+			// Timeout gave up on, so we leave it alone.
+			keepAlive = req.keepAlive() && (resp == nil || !hasToken(resp.Headers, "Connection", "close")) && drainBody(reqBody)
+		}
+		if resp == nil {
+			resp, _ = NewResponse(500, "")
+		}
+		if req != nil {
+			resp.Request = req // so WriteTo knows to leave out the body of a response to HEAD.
+		}
+		if !prepareResponse(resp, req) {
+			keepAlive = false
+		}
+		if keepAlive = keepAlive && !hasToken(resp.Headers, "Connection", "close"); !keepAlive {
+			resp.Headers.Set("Connection", "close")
+		} else if req.Proto == "HTTP/1.0" {
+			resp.Headers.Set("Connection", "keep-alive") // 1.0 clients need to be told explicitly.
+		}
+
+		_, err = resp.WriteTo(bw)
+		if req != nil {
+			req.forms.removeAll() // the response might have been streaming from an upload, so not before now.
+		}
+		if err != nil {
+			s.logf("writing response to %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+		// if the client pipelined another request, it's already in the buffer: hold the response back
+		// so the two go out together. Otherwise, the client's waiting on us.
+		if !keepAlive || br.Buffered() == 0 {
+			if err := bw.Flush(); err != nil {
+				s.logf("writing response to %s: %v", conn.RemoteAddr(), err)
+				return
+			}
+		}
+		if !keepAlive {
+			return
+		}
+	}
+}
+
+func (s *Server) readHeaderTimeout() time.Duration {
+	if s.ReadHeaderTimeout != 0 {
+		return s.ReadHeaderTimeout
+	}
+	return s.ReadTimeout
+}
+
+func (s *Server) idleTimeout() time.Duration {
+	if s.IdleTimeout != 0 {
+		return s.IdleTimeout
+	}
+	return s.ReadTimeout
+}
+
+// deadline returns the time d after start, or the zero time, i.e. no deadline, if d isn't positive.
+func deadline(start time.Time, d time.Duration) time.Time {
+	if d <= 0 {
+		return time.Time{}
+	}
+	return start.Add(d)
+}
+
+// isTimeout reports whether err is from a deadline passing.
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// maxDrainBytes is the most of a request body the server will skip over to reuse the connection.
+// Past that, it's cheaper to make the client reconnect than to read the rest.
+const maxDrainBytes = 256 << 10
+
+// bodyTooLarge reports whether reading rc, a request body, failed for going over the Parser's MaxBodyBytes.
+// It's safe to call while someone's still reading it, e.g a handler Timeout gave up on.
+func bodyTooLarge(rc io.ReadCloser) bool {
+	switch b := rc.(type) {
+	case *body:
+		return atomic.LoadInt32(&b.tooLarge) != 0
+	case *continueReader:
+		return bodyTooLarge(b.rc)
+	default:
+		return false
+	}
+}
+
+// drainBody skips whatever's left of a request body, reporting whether the connection can be reused afterwards.
+// It can't if the client's waiting for a 100 Continue that never came: it might yet send the body, or not.
+func drainBody(rc io.ReadCloser) bool {
+	switch b := rc.(type) {
+	case *body:
+		return b.drain(maxDrainBytes)
+	case *continueReader:
+		return b.stop() && drainBody(b.rc)
+	default:
+		return true
+	}
+}
+
+// prepareResponse makes sure the client can tell where the body ends:
+// a Response built by hand might have neither a Content-Length nor a Transfer-Encoding.
+// If the body's length is unknown, it's chunked, or, for HTTP/1.0 clients which don't understand that,
+// ended by closing the connection; prepareResponse returns false in that case.
+func prepareResponse(resp *Response, req *Request) (keepAlive bool) {
+	if resp.Headers.Has("Content-Length") || resp.Headers.Has("Transfer-Encoding") || !statusHasBody(resp.StatusCode) {
+		return true
+	}
+	n := bodyLength(resp.Body, resp.ContentLength)
+	if resp.Body == nil && resp.ContentLength < 0 {
+		n = -1 // a response to HEAD, whose body was left out, but would be of unknown length; see Compress.
+	}
+	switch {
+	case n >= 0:
+		resp.Headers.Set("Content-Length", fmt.Sprintf("%d", n)) // for HEAD, too: it's what a GET would get.
+	case req != nil && req.Proto == "HTTP/1.0":
+		// the GET would be ended by closing the connection; a HEAD has no body to end.
+		return req.Method == "HEAD"
+	default:
+		resp.Headers.Set("Transfer-Encoding", "chunked")
+	}
+	return true
+}
+
+// trackListener adds l to (or, if !add, removes it from) the set of open listeners.
+// Adding returns false if the server's already closed.
+func (s *Server) trackListener(l net.Listener, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !add {
+		delete(s.listeners, l)
+		return true
+	}
+	if s.closed {
+		return false
+	}
+	if s.listeners == nil {
+		s.listeners = make(map[net.Listener]struct{})
+	}
+	s.listeners[l] = struct{}{}
+	return true
+}
+
+// trackConn is like trackListener, but for connections.
+func (s *Server) trackConn(c net.Conn, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !add {
+		delete(s.conns, c)
+		return true
+	}
+	if s.closed {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]struct{})
+	}
+	s.conns[c] = struct{}{}
+	return true
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *Server) logf(format string, args ...any) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
@@ -0,0 +1,298 @@
+package http
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// startServer runs s on a random local port, returning the address to dial.
+func startServer(t *testing.T, s *Server) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+	return l.Addr().String()
+}
+
+// roundTrip dials addr, sends raw, and reads a single response, body and all.
+func roundTrip(t *testing.T, addr, raw string) *Response {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(raw)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	resp, err := ReadResponse(bufio.NewReader(conn))
+	if err != nil {
+		t.Fatalf("ReadResponse: %v", err)
+	}
+	bodyOf(t, resp) // before we hang up.
+	return resp
+}
+
+func TestServer(t *testing.T) {
+	addr := startServer(t, &Server{Handler: HandlerFunc(func(r *Request) *Response {
+		switch r.Path {
+		case "/nil":
+			return nil
+		case "/by-hand": // no Content-Length; the server should add one.
+			resp := &Response{StatusCode: 201}
+			resp.SetBody([]byte("made by hand"))
+			return resp
+		case "/stream": // unknown length; the server should chunk it.
+			return &Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("streamed"))}
+		}
+		body, err := r.BodyBytes()
+		if err != nil {
+			return nil
+		}
+		resp, _ := NewResponse(200, fmt.Sprintf("%s %s: %s", r.Method, r.Path, body))
+		return resp
+	})})
+
+	for name, tt := range map[string]struct {
+		raw        string
+		wantStatus int
+		wantBody   string
+	}{
+		"GET":         {"GET /hello HTTP/1.1\r\nHost: localhost\r\n\r\n", 200, "GET /hello: "},
+		"POST":        {"POST /echo HTTP/1.1\r\nHost: localhost\r\nContent-Length: 2\r\n\r\nhi", 200, "POST /echo: hi"},
+		"nil":         {"GET /nil HTTP/1.1\r\nHost: localhost\r\n\r\n", 500, "Internal Server Error"},
+		"by hand":     {"GET /by-hand HTTP/1.1\r\nHost: localhost\r\n\r\n", 201, "made by hand"},
+		"stream":      {"GET /stream HTTP/1.1\r\nHost: localhost\r\n\r\n", 200, "streamed"},
+		"chunked":     {"POST /echo HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n2\r\nhi\r\n0\r\n\r\n", 200, "POST /echo: hi"},
+		"bad request": {"GET /hello HTTP/1.1\r\n\r\n", 400, "Bad Request"}, // missing Host
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp := roundTrip(t, addr, tt.raw)
+			if body := bodyOf(t, resp); resp.StatusCode != tt.wantStatus || body != tt.wantBody {
+				t.Errorf("got %d %q, want %d %q", resp.StatusCode, body, tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestServerKeepAlive(t *testing.T) {
+	addr := startServer(t, &Server{Handler: HandlerFunc(func(r *Request) *Response {
+		resp, _ := NewResponse(200, r.Path)
+		return resp
+	})})
+
+	for name, tt := range map[string]struct {
+		raw        string   // sent all at once, i.e, pipelined
+		wantBodies []string // in order
+		wantClosed bool     // should the server hang up after the last response?
+	}{
+		"HTTP/1.1 pipelined": {
+			raw:        "GET /1 HTTP/1.1\r\nHost: localhost\r\n\r\nGET /2 HTTP/1.1\r\nHost: localhost\r\n\r\nGET /3 HTTP/1.1\r\nHost: localhost\r\n\r\n",
+			wantBodies: []string{"/1", "/2", "/3"},
+		},
+		"HTTP/1.1 close": {
+			raw:        "GET /1 HTTP/1.1\r\nHost: localhost\r\n\r\nGET /2 HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\nGET /3 HTTP/1.1\r\nHost: localhost\r\n\r\n",
+			wantBodies: []string{"/1", "/2"},
+			wantClosed: true,
+		},
+		"HTTP/1.0 default": {
+			raw:        "GET /1 HTTP/1.0\r\nHost: localhost\r\n\r\nGET /2 HTTP/1.0\r\nHost: localhost\r\n\r\n",
+			wantBodies: []string{"/1"},
+			wantClosed: true,
+		},
+		"HTTP/1.0 keep-alive": {
+			raw:        "GET /1 HTTP/1.0\r\nHost: localhost\r\nConnection: keep-alive\r\n\r\nGET /2 HTTP/1.0\r\nHost: localhost\r\n\r\n",
+			wantBodies: []string{"/1", "/2"},
+			wantClosed: true,
+		},
+		"Transfer-Encoding and Content-Length": {
+			// a proxy that goes by the Content-Length would think "0\r\n\r\nGET /2" is the body, and never see /2.
+			raw:        "POST /1 HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\nContent-Length: 5\r\n\r\n0\r\n\r\nGET /2 HTTP/1.1\r\nHost: localhost\r\n\r\n",
+			wantBodies: []string{"/1"},
+			wantClosed: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			defer conn.Close()
+			conn.Write([]byte(tt.raw))
+			br := bufio.NewReader(conn)
+			for i, want := range tt.wantBodies {
+				resp, err := ReadResponse(br)
+				if err != nil {
+					t.Fatalf("response %d: ReadResponse: %v", i, err)
+				}
+				if body := bodyOf(t, resp); body != want {
+					t.Errorf("response %d: body = %q, want %q", i, body, want)
+				}
+				if last := i == len(tt.wantBodies)-1; last && tt.wantClosed && !hasToken(resp.Headers, "Connection", "close") {
+					t.Errorf("response %d: got headers %v, want Connection: close", i, resp.Headers)
+				}
+			}
+			if tt.wantClosed {
+				if _, err := ReadResponse(br); err != io.EOF {
+					t.Errorf("after last response, ReadResponse returned %v, want io.EOF", err)
+				}
+			}
+		})
+	}
+}
+
+func TestServerLimits(t *testing.T) {
+	addr := startServer(t, &Server{
+		Parser: Parser{MaxRequestLineBytes: 64, MaxHeaderBytes: 128, MaxHeaderCount: 3, MaxBodyBytes: 8},
+		Handler: HandlerFunc(func(r *Request) *Response {
+			// a chunked body's length isn't known up front, so only reading it finds out it's too long; the Server
+			// answers with a 413 whatever the handler makes of the error.
+			if _, err := r.BodyBytes(); err != nil {
+				return nil
+			}
+			resp, _ := NewResponse(200, "")
+			return resp
+		}),
+	})
+	for name, tt := range map[string]struct {
+		raw        string
+		wantStatus int
+	}{
+		"within limits":      {"POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 8\r\n\r\n12345678", 200},
+		"long request line":  {"GET /" + strings.Repeat("a", 64) + " HTTP/1.1\r\nHost: localhost\r\n\r\n", 414},
+		"long header":        {"GET / HTTP/1.1\r\nHost: localhost\r\nX-Big: " + strings.Repeat("a", 128) + "\r\n\r\n", 431},
+		"too many headers":   {"GET / HTTP/1.1\r\nHost: localhost\r\nA: 1\r\nB: 2\r\nC: 3\r\n\r\n", 431},
+		"large body":         {"POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 9\r\n\r\n123456789", 413},
+		"large chunked body": {"POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n5\r\n12345\r\n5\r\n67890\r\n0\r\n\r\n", 413},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if resp := roundTrip(t, addr, tt.raw); resp.StatusCode != tt.wantStatus {
+				t.Errorf("got %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestServerHEADAndOPTIONS(t *testing.T) {
+	m := NewMux()
+	m.HandleFunc("GET", "/page", func(r *Request) *Response {
+		resp, _ := NewResponse(200, "hello")
+		return resp
+	})
+	m.HandleFunc("GET", "/stream", func(r *Request) *Response {
+		return &Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("streamed"))}
+	})
+	addr := startServer(t, &Server{Handler: m})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	// pipelined, so a HEAD response with a body would garble the ones after it.
+	conn.Write([]byte("HEAD /page HTTP/1.1\r\nHost: localhost\r\n\r\n" +
+		"HEAD /stream HTTP/1.1\r\nHost: localhost\r\n\r\n" +
+		"GET /page HTTP/1.1\r\nHost: localhost\r\n\r\n" +
+		"OPTIONS /page HTTP/1.1\r\nHost: localhost\r\n\r\n" +
+		"OPTIONS * HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n"))
+	got, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\n" +
+		"HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		"HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nhello" +
+		"HTTP/1.1 204 No Content\r\nAllow: GET, HEAD, OPTIONS\r\n\r\n" +
+		"HTTP/1.1 204 No Content\r\nAllow: GET, HEAD, OPTIONS\r\nConnection: close\r\n\r\n"
+	if string(got) != want {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestServerTimeouts(t *testing.T) {
+	handler := HandlerFunc(func(r *Request) *Response {
+		if r.Path == "/slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		if _, err := r.BodyBytes(); err != nil {
+			resp, _ := NewResponse(408, "")
+			return resp
+		}
+		resp, _ := NewResponse(200, r.Path)
+		return resp
+	})
+	req := func(path, extra string) string {
+		return "GET " + path + " HTTP/1.1\r\nHost: localhost\r\n" + extra + "\r\n"
+	}
+	ok := func(path string) string {
+		return fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n%s", len(path), path)
+	}
+	for name, tt := range map[string]struct {
+		server *Server
+		parts  []string // sent with a pause between each
+		want   string   // everything the server sends before hanging up
+	}{
+		"header timeout": {
+			server: &Server{ReadHeaderTimeout: 50 * time.Millisecond},
+			parts:  []string{"GET / HTTP/1.1\r\n", "Host: localhost\r\n\r\n"},
+		},
+		"read timeout covers headers": {
+			server: &Server{ReadTimeout: 50 * time.Millisecond},
+			parts:  []string{"GET / HTTP/1.1\r\n", "Host: localhost\r\n\r\n"},
+		},
+		"body timeout": {
+			server: &Server{ReadTimeout: 50 * time.Millisecond},
+			parts:  []string{"PUT / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 4\r\n\r\nab", "cd"},
+			want:   "HTTP/1.1 408 Request Timeout\r\nContent-Length: 15\r\nConnection: close\r\n\r\nRequest Timeout",
+		},
+		"idle timeout": {
+			server: &Server{IdleTimeout: 50 * time.Millisecond},
+			parts:  []string{req("/1", ""), req("/2", "")},
+			want:   ok("/1"),
+		},
+		"idle time isn't header time": {
+			server: &Server{ReadHeaderTimeout: 50 * time.Millisecond, IdleTimeout: time.Minute},
+			parts:  []string{req("/1", ""), req("/2", "Connection: close\r\n")},
+			want:   ok("/1") + "HTTP/1.1 200 OK\r\nContent-Length: 2\r\nConnection: close\r\n\r\n/2",
+		},
+		"write timeout": {
+			server: &Server{WriteTimeout: 50 * time.Millisecond},
+			parts:  []string{req("/slow", "")},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tt.server.Handler = handler
+			tt.server.ErrorLog = log.New(io.Discard, "", 0)
+			conn, err := net.Dial("tcp", startServer(t, tt.server))
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			defer conn.Close()
+			for i, part := range tt.parts {
+				if i > 0 {
+					time.Sleep(100 * time.Millisecond)
+				}
+				conn.Write([]byte(part)) // might fail, if the server's hung up already.
+			}
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			got, err := io.ReadAll(conn)
+			if err != nil && !errors.Is(err, syscall.ECONNRESET) {
+				t.Fatalf("the server didn't hang up: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
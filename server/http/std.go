@@ -0,0 +1,307 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Conversions to and from net/http, so services can move over one handler at a time, and reuse handlers written
+// for net/http. The conversions are shallow: bodies are shared, not copied, so a converted request or response
+// should be used in place of the original, not alongside it.
+//
+// net/http keeps headers in a map, so their order is lost on the way there; on the way back, they come out sorted by key.
+// It also keeps Host outside of the headers, in the Request's Host field.
+
+// ToStdRequest converts r to a *net/http.Request, with r's context.
+// A request with a Scheme and Authority gets an absolute URL, so it can be sent with a net/http Client.
+func ToStdRequest(r *Request) (*http.Request, error) {
+	if r.Method == "" {
+		return nil, errors.New("http: ToStdRequest: missing method")
+	}
+	u := &url.URL{Scheme: r.Scheme, Host: r.Authority, Path: r.Path, RawQuery: r.RawQuery}
+	if r.Path == "*" {
+		u = &url.URL{Path: "*"}
+	}
+	proto := r.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+	major, minor, ok := http.ParseHTTPVersion(proto)
+	if !ok {
+		return nil, fmt.Errorf("http: ToStdRequest: malformed protocol %q", proto)
+	}
+	sr := &http.Request{
+		Method:        r.Method,
+		URL:           u,
+		Proto:         proto,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        toStdHeader(r.Headers),
+		Body:          r.Body,
+		ContentLength: bodyLength(r.Body, r.ContentLength),
+		Host:          r.Authority,
+		Form:          r.Form,
+		PostForm:      r.PostForm,
+		MultipartForm: r.MultipartForm,
+	}
+	if sr.Host == "" {
+		sr.Host = r.Headers.Get("Host")
+	}
+	sr.Header.Del("Host")
+	if sr.Body == nil {
+		sr.Body = http.NoBody
+	}
+	if isChunked(r.Headers) {
+		sr.TransferEncoding = []string{"chunked"}
+		sr.Header.Del("Transfer-Encoding") // net/http keeps it in TransferEncoding.
+	}
+	if len(r.Trailer) > 0 {
+		sr.Trailer = toStdHeader(r.Trailer)
+	}
+	return sr.WithContext(r.Context()), nil
+}
+
+// FromStdRequest converts a *net/http.Request to a *Request, with sr's context. HTTP/2 and HTTP/3 requests
+// become HTTP/1.1 ones. A request with an absolute URL, as on the client side, gets a Scheme and Authority.
+func FromStdRequest(sr *http.Request) (*Request, error) {
+	if sr.URL == nil {
+		return nil, errors.New("http: FromStdRequest: missing URL")
+	}
+	r := &Request{
+		Method:        sr.Method,
+		Path:          sr.URL.Path,
+		RawQuery:      sr.URL.RawQuery,
+		Proto:         sr.Proto,
+		Body:          sr.Body,
+		ContentLength: sr.ContentLength,
+		Form:          sr.Form,
+		PostForm:      sr.PostForm,
+		MultipartForm: sr.MultipartForm,
+		ctx:           sr.Context(),
+	}
+	if r.Method == "" {
+		r.Method = "GET" // as net/http does.
+	}
+	if sr.URL.IsAbs() {
+		r.Scheme, r.Authority = strings.ToLower(sr.URL.Scheme), sr.URL.Host
+	}
+	if r.Path == "" {
+		r.Path = "/"
+	}
+	if !validProto(r.Proto) {
+		r.Proto = "HTTP/1.1"
+	}
+	host := sr.Host
+	if host == "" {
+		host = sr.URL.Host
+	}
+	if host != "" {
+		r.Headers = Headers{{"Host", host}}
+	}
+	r.Headers = append(r.Headers, fromStdHeader(sr.Header)...)
+	if len(sr.TransferEncoding) > 0 && sr.TransferEncoding[len(sr.TransferEncoding)-1] == "chunked" {
+		r.Headers.Set("Transfer-Encoding", "chunked")
+	}
+	if r.Body == http.NoBody {
+		r.Body, r.ContentLength = nil, 0
+	}
+	if len(sr.Trailer) > 0 {
+		r.Trailer = fromStdHeader(sr.Trailer)
+	}
+	return r, nil
+}
+
+// ToStdResponse converts resp to a *net/http.Response. If resp has a Request, so does the result.
+func ToStdResponse(resp *Response) (*http.Response, error) {
+	proto := resp.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+	major, minor, ok := http.ParseHTTPVersion(proto)
+	if !ok {
+		return nil, fmt.Errorf("http: ToStdResponse: malformed protocol %q", proto)
+	}
+	sr := &http.Response{
+		Status:        fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode)),
+		StatusCode:    resp.StatusCode,
+		Proto:         proto,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        toStdHeader(resp.Headers),
+		Body:          resp.Body,
+		ContentLength: bodyLength(resp.Body, resp.ContentLength),
+		Uncompressed:  resp.Uncompressed,
+	}
+	if sr.Body == nil {
+		sr.Body = http.NoBody
+	}
+	if isChunked(resp.Headers) {
+		sr.TransferEncoding = []string{"chunked"}
+		sr.Header.Del("Transfer-Encoding")
+	}
+	if len(resp.Trailer) > 0 {
+		sr.Trailer = toStdHeader(resp.Trailer)
+	}
+	if resp.Request != nil {
+		var err error
+		if sr.Request, err = ToStdRequest(resp.Request); err != nil {
+			return nil, err
+		}
+	}
+	return sr, nil
+}
+
+// FromStdResponse converts a *net/http.Response to a *Response. If sr has a Request, so does the result.
+func FromStdResponse(sr *http.Response) (*Response, error) {
+	resp := &Response{
+		StatusCode:    sr.StatusCode,
+		Proto:         sr.Proto,
+		Headers:       fromStdHeader(sr.Header),
+		Body:          sr.Body,
+		ContentLength: sr.ContentLength,
+		Uncompressed:  sr.Uncompressed,
+	}
+	if !validProto(resp.Proto) {
+		resp.Proto = "HTTP/1.1"
+	}
+	if len(sr.TransferEncoding) > 0 && sr.TransferEncoding[len(sr.TransferEncoding)-1] == "chunked" {
+		resp.Headers.Set("Transfer-Encoding", "chunked")
+	}
+	if resp.Body == http.NoBody {
+		resp.Body, resp.ContentLength = nil, 0
+	}
+	if len(sr.Trailer) > 0 {
+		resp.Trailer = fromStdHeader(sr.Trailer)
+	}
+	if sr.Request != nil {
+		var err error
+		if resp.Request, err = FromStdRequest(sr.Request); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// FromStdHandler mounts a net/http Handler on our Server: it's called with the converted request, and what it
+// writes becomes the Response. The response is buffered in memory until the handler returns, so this isn't
+// the place for handlers that stream, e.g server-sent events.
+func FromStdHandler(h http.Handler) Handler {
+	return HandlerFunc(func(r *Request) *Response {
+		sr, err := ToStdRequest(r)
+		if err != nil {
+			resp, _ := NewResponse(400, "")
+			return resp
+		}
+		sr.RequestURI = r.RequestTarget() // as a net/http server would set it; handlers sometimes look at it.
+		rec := &responseRecorder{header: make(http.Header)}
+		h.ServeHTTP(rec, sr)
+		if sr.MultipartForm != nil && sr.MultipartForm != r.MultipartForm {
+			sr.MultipartForm.RemoveAll() // parsed by h, into the copy it was given; the response is in memory, so it's done with them.
+		}
+		return rec.response()
+	})
+}
+
+// ToStdHandler mounts our Handler on a net/http Server. The Response body is streamed to the client,
+// and any trailer is sent after it. net/http frames the body and manages the connection itself, so the
+// Transfer-Encoding and Connection headers are left out.
+func ToStdHandler(h Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, sr *http.Request) {
+		r, err := FromStdRequest(sr)
+		if err != nil {
+			http.Error(w, http.StatusText(400), 400)
+			return
+		}
+		// net/http only cleans up sr's MultipartForm, not what h parses from r, or a copy of it.
+		r.forms = new(formFiles)
+		defer r.forms.removeAll()
+		resp := h.ServeHTTP(r)
+		if resp == nil {
+			resp, _ = NewResponse(500, "")
+		}
+		resp = applyPreconditions(r, resp) // as our Server would.
+		header := w.Header()
+		for _, h := range resp.Headers {
+			if h.Key == "Transfer-Encoding" || h.Key == "Connection" {
+				continue
+			}
+			header.Add(h.Key, h.Value)
+		}
+		w.WriteHeader(resp.StatusCode)
+		if resp.Body != nil {
+			defer resp.Body.Close()
+			io.Copy(w, resp.Body)
+		}
+		for _, t := range resp.Trailer { // only known for sure now that the body's been read.
+			header.Add(http.TrailerPrefix+t.Key, t.Value)
+		}
+	})
+}
+
+// responseRecorder is an http.ResponseWriter that keeps what's written to it, for FromStdHandler.
+type responseRecorder struct {
+	header http.Header
+	status int // 0 until WriteHeader is called, explicitly or by the first Write
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) Header() http.Header { return rec.header }
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+func (rec *responseRecorder) Write(p []byte) (int, error) {
+	rec.WriteHeader(200)
+	return rec.body.Write(p)
+}
+
+// response builds the Response the handler wrote.
+func (rec *responseRecorder) response() *Response {
+	rec.WriteHeader(200) // if the handler wrote nothing at all.
+	header := rec.header.Clone()
+	for key := range header {
+		if strings.HasPrefix(key, http.TrailerPrefix) {
+			delete(header, key) // we send the whole body at once, with a Content-Length, so there's no trailer.
+		}
+	}
+	header.Del("Trailer")
+	resp := &Response{StatusCode: rec.status, Headers: fromStdHeader(header)}
+	if rec.body.Len() > 0 || resp.hasBody() {
+		resp.SetBody(rec.body.Bytes())
+	}
+	return resp
+}
+
+// toStdHeader converts h to a net/http Header.
+func toStdHeader(h Headers) http.Header {
+	sh := make(http.Header, len(h))
+	for _, f := range h {
+		sh.Add(f.Key, f.Value)
+	}
+	return sh
+}
+
+// fromStdHeader converts a net/http Header to Headers, sorted by key; values with the same key keep their order.
+func fromStdHeader(sh http.Header) Headers {
+	keys := make([]string, 0, len(sh))
+	for k := range sh {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var h Headers
+	for _, k := range keys {
+		for _, v := range sh[k] {
+			h.Add(k, v)
+		}
+	}
+	return h
+}
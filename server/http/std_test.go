@@ -0,0 +1,145 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestStdRequest(t *testing.T) {
+	for name, raw := range map[string]string{
+		"GET":      "GET /search?q=go HTTP/1.1\r\nHost: example.com\r\nAccept: text/html\r\nX-Multi: 1\r\nX-Multi: 2\r\n\r\n",
+		"POST":     "POST /echo HTTP/1.1\r\nHost: example.com\r\nContent-Length: 2\r\nContent-Type: text/plain\r\n\r\nhi",
+		"absolute": "GET http://example.com/abs HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		"HTTP/1.0": "GET / HTTP/1.0\r\nHost: example.com\r\n\r\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			want, err := ParseRequest(raw)
+			if err != nil {
+				t.Fatalf("ParseRequest: %v", err)
+			}
+			sr, err := ToStdRequest(&want)
+			if err != nil {
+				t.Fatalf("ToStdRequest: %v", err)
+			}
+			if sr.Host != "example.com" || sr.Header.Get("Host") != "" {
+				t.Errorf("got Host %q and Host header %q, want example.com and none", sr.Host, sr.Header.Get("Host"))
+			}
+			got, err := FromStdRequest(sr)
+			if err != nil {
+				t.Fatalf("FromStdRequest: %v", err)
+			}
+			// the headers come back sorted, after Host.
+			if gotRaw, wantRaw := got.String(), sortedHeaders(want.String()); gotRaw != wantRaw {
+				t.Errorf("round trip: got\n%q\nwant\n%q", gotRaw, wantRaw)
+			}
+		})
+	}
+}
+
+// sortedHeaders sorts the header lines of a raw message, Host first, as they come back from net/http.
+func sortedHeaders(raw string) string {
+	head, body, _ := strings.Cut(raw, "\r\n\r\n")
+	lines := strings.Split(head, "\r\n")
+	fields := lines[1:]
+	key := func(i int) string {
+		if k, _, _ := strings.Cut(fields[i], ":"); k != "Host" {
+			return k
+		}
+		return "" // first.
+	}
+	sort.SliceStable(fields, func(i, j int) bool { return key(i) < key(j) })
+	return lines[0] + "\r\n" + strings.Join(fields, "\r\n") + "\r\n\r\n" + body
+}
+
+func TestStdResponse(t *testing.T) {
+	want := &Response{StatusCode: 404, Proto: "HTTP/1.1", Headers: Headers{{"Content-Length", "5"}, {"Content-Type", "text/plain"}}}
+	want.SetBody([]byte("nope!"))
+	sr, err := ToStdResponse(want)
+	if err != nil {
+		t.Fatalf("ToStdResponse: %v", err)
+	}
+	if sr.Status != "404 Not Found" || sr.ContentLength != 5 || sr.Header.Get("Content-Type") != "text/plain" {
+		t.Errorf("got %q, ContentLength %d, Content-Type %q", sr.Status, sr.ContentLength, sr.Header.Get("Content-Type"))
+	}
+	got, err := FromStdResponse(sr)
+	if err != nil {
+		t.Fatalf("FromStdResponse: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip: got %#v, want %#v", got, want)
+	}
+}
+
+func TestFromStdHandler(t *testing.T) {
+	std := http.NewServeMux()
+	std.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		var in struct{ Name string }
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(201)
+		json.NewEncoder(w).Encode(map[string]string{"hello": in.Name, "uri": r.RequestURI, "q": r.URL.Query().Get("q")})
+	})
+	std.HandleFunc("/empty", func(w http.ResponseWriter, r *http.Request) {})
+	addr := startServer(t, &Server{Handler: FromStdHandler(std)})
+
+	for name, tt := range map[string]struct {
+		method, path, body string
+		wantStatus         int
+		wantBody           string
+	}{
+		"json":      {"POST", "/json?q=1", `{"Name":"gopher"}`, 201, `{"hello":"gopher","q":"1","uri":"/json?q=1"}` + "\n"},
+		"bad json":  {"POST", "/json", `{`, 400, "unexpected EOF\n"},
+		"empty":     {"GET", "/empty", "", 200, ""},
+		"not found": {"GET", "/nope", "", 404, "404 page not found\n"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, _ := NewRequest(tt.method, tt.path, addr, tt.body)
+			resp, err := new(Client).Do(context.Background(), req)
+			if err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+			if body := bodyOf(t, resp); resp.StatusCode != tt.wantStatus || body != tt.wantBody {
+				t.Errorf("got %d %q, want %d %q", resp.StatusCode, body, tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestToStdHandler(t *testing.T) {
+	h := ToStdHandler(HandlerFunc(func(r *Request) *Response {
+		body, _ := r.BodyBytes()
+		resp := &Response{
+			StatusCode: 202,
+			Headers:    Headers{{"Transfer-Encoding", "chunked"}, {"X-Path", r.Path}},
+			Body:       io.NopCloser(strings.NewReader(r.Method + " " + string(body))),
+			Trailer:    Headers{{"X-Checksum", "abc"}},
+		}
+		return resp
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("PUT", "/things/1", strings.NewReader("data")))
+	res := rec.Result()
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != 202 || string(body) != "PUT data" {
+		t.Errorf("got %d %q, want 202 %q", res.StatusCode, body, "PUT data")
+	}
+	if got := res.Header.Get("X-Path"); got != "/things/1" {
+		t.Errorf("X-Path is %q, want /things/1", got)
+	}
+	if got := res.Header.Get("Transfer-Encoding"); got != "" {
+		t.Errorf("Transfer-Encoding is %q, want it left to net/http", got)
+	}
+	if got := res.Trailer.Get("X-Checksum"); got != "abc" {
+		t.Errorf("trailer X-Checksum is %q, want abc", got)
+	}
+}
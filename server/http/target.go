@@ -0,0 +1,88 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// The request-target comes in four forms (RFC 9112 section 3.2):
+//
+//	origin-form    /search?q=go               what you'd normally send to a server
+//	absolute-form  http://example.com/search  what you'd send to a proxy
+//	authority-form example.com:443            only for CONNECT
+//	asterisk-form  *                          only for OPTIONS; asks about the server as a whole
+//
+// parseTarget fills in the Scheme, Authority, Path and RawQuery of r from the given target.
+func (r *Request) parseTarget(target string) error {
+	switch {
+	case target == "*":
+		if r.Method != "OPTIONS" {
+			return errors.New("asterisk-form target '*' is only allowed for OPTIONS")
+		}
+		r.Path = "*"
+		return nil
+	case strings.HasPrefix(target, "/"):
+		return r.parseOrigin(target)
+	case r.Method == "CONNECT":
+		// authority-form is host:port and nothing else: no scheme, no userinfo, no path.
+		if _, port, err := net.SplitHostPort(target); err != nil || port == "" || strings.ContainsAny(target, "/@?#") {
+			return fmt.Errorf("CONNECT target %q should be of form 'host:port'", target)
+		}
+		r.Authority = target
+		return nil
+	default:
+		u, err := url.Parse(target)
+		if err != nil || u.Scheme == "" || u.Host == "" || u.Fragment != "" || u.User != nil {
+			return fmt.Errorf("target %q should be a path starting with / or an absolute URL", target)
+		}
+		r.Scheme, r.Authority = strings.ToLower(u.Scheme), u.Host
+		r.Path, r.RawQuery = u.Path, u.RawQuery
+		if r.Path == "" {
+			r.Path = "/" // "http://example.com" is the same as "http://example.com/"
+		}
+		return nil
+	}
+}
+
+// parseOrigin parses an origin-form target: an absolute path, optionally followed by a '?' and the query.
+func (r *Request) parseOrigin(target string) error {
+	rawPath, rawQuery, _ := strings.Cut(target, "?")
+	if strings.Contains(target, "#") {
+		return fmt.Errorf("target %q should not contain a fragment", target)
+	}
+	path, err := url.PathUnescape(rawPath)
+	if err != nil {
+		return fmt.Errorf("target %q has a malformed path: %w", target, err)
+	}
+	r.Path, r.RawQuery = path, rawQuery
+	return nil
+}
+
+// Query parses RawQuery and returns the query parameters; e.g, "?a=1&b=2&a=3" is {"a": ["1", "3"], "b": ["2"]}.
+// Malformed pairs are skipped. The returned map is a fresh copy every time, so it's safe to modify.
+func (r *Request) Query() url.Values {
+	v, _ := url.ParseQuery(r.RawQuery)
+	return v
+}
+
+// RequestTarget returns the request-target to write on the request line, built from the
+// Scheme, Authority, Path and RawQuery; it's the inverse of what ReadRequest does.
+func (r *Request) RequestTarget() string {
+	switch {
+	case r.Path == "*":
+		return "*"
+	case r.Method == "CONNECT" && r.Authority != "":
+		return r.Authority
+	}
+	u := url.URL{Path: r.Path, RawQuery: r.RawQuery}
+	if r.Scheme != "" {
+		u.Scheme, u.Host = r.Scheme, r.Authority
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String()
+}
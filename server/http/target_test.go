@@ -0,0 +1,67 @@
+package http
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestRequestTarget(t *testing.T) {
+	for name, tt := range map[string]struct {
+		method, target string
+		want           Request // only the target fields are filled in.
+	}{
+		"origin":           {"GET", "/", Request{Path: "/"}},
+		"origin w/ query":  {"GET", "/search?q=go&page=2", Request{Path: "/search", RawQuery: "q=go&page=2"}},
+		"escaped path":     {"GET", "/a%20b/c?x=%20", Request{Path: "/a b/c", RawQuery: "x=%20"}},
+		"absolute":         {"GET", "http://Example.com/a?b=c", Request{Scheme: "http", Authority: "Example.com", Path: "/a", RawQuery: "b=c"}},
+		"absolute no path": {"GET", "HTTPS://example.com", Request{Scheme: "https", Authority: "example.com", Path: "/"}},
+		"authority":        {"CONNECT", "example.com:443", Request{Authority: "example.com:443"}},
+		"asterisk":         {"OPTIONS", "*", Request{Path: "*"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got := Request{Method: tt.method}
+			if err := got.parseTarget(tt.target); err != nil {
+				t.Fatalf("parseTarget(%q) returned error: %v", tt.target, err)
+			}
+			tt.want.Method = tt.method
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTarget(%q) = %+v, want %+v", tt.target, got, tt.want)
+			}
+			// and back again
+			if name != "absolute" && name != "absolute no path" {
+				if target := got.RequestTarget(); target != tt.target {
+					t.Errorf("RequestTarget() = %q, want %q", target, tt.target)
+				}
+			}
+		})
+	}
+}
+
+func TestRequestTargetErrors(t *testing.T) {
+	for name, tt := range map[string]struct{ method, target string }{
+		"asterisk w/o OPTIONS":  {"GET", "*"},
+		"authority w/o CONNECT": {"GET", "example.com:443"},
+		"CONNECT w/ path":       {"CONNECT", "example.com:443/path"},
+		"CONNECT w/o port":      {"CONNECT", "example.com"},
+		"bad escape":            {"GET", "/a%zz"},
+		"fragment":              {"GET", "/a#b"},
+		"relative":              {"GET", "a/b"},
+	} {
+		r := Request{Method: tt.method}
+		if err := r.parseTarget(tt.target); err == nil {
+			t.Errorf("%s: parseTarget(%q) returned no error", name, tt.target)
+		}
+	}
+}
+
+func TestRequestQuery(t *testing.T) {
+	r, err := ParseRequest("GET /search?q=%22of+Emrakul%22&order=released&q=second HTTP/1.1\r\nHost: scryfall.com\r\n\r\n")
+	if err != nil {
+		t.Fatalf("ParseRequest returned error: %v", err)
+	}
+	want := url.Values{"q": {`"of Emrakul"`, "second"}, "order": {"released"}}
+	if got := r.Query(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Query() = %v, want %v", got, want)
+	}
+}
@@ -1,19 +1,22 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
-	"net"
 	"os"
-	"strings"
+	"time"
+
+	"rochi/server/http"
 )
 
 // define flags
 var (
 	host, path, method string
 	port               int
+	timeout            time.Duration
 )
 
 func main() {
@@ -22,49 +25,35 @@ func main() {
 	flag.StringVar(&host, "host", "localhost", "host to connect to")
 	flag.StringVar(&path, "path", "/", "path to request")
 	flag.IntVar(&port, "port", 8080, "port to connect to")
+	flag.DurationVar(&timeout, "timeout", 30*time.Second, "give up on the whole exchange after this long")
 	flag.Parse()
 
-	// ResolveTCP Addr is a slightly more convenient way of creating a TCPAddr
-	ip, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("%s:%d", host, port))
+	req, err := http.NewRequest(method, path, fmt.Sprintf("%s:%d", host, port), "")
 	if err != nil {
-		panic(err)
+		log.Fatalf("building request: %v", err)
 	}
+	req = req.WithHeader("User-Agent", "Roach")
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	// dial(connect to) the remote host using the TCP addr we just created
-	conn, err := net.DialTCP("tcp", nil, ip)
+	client := &http.Client{DialTimeout: 5 * time.Second, ResponseHeaderTimeout: 10 * time.Second}
+	resp, err := client.Do(ctx, req)
 	if err != nil {
-		panic(err)
+		log.Fatalf("sending request to %s:%d: %v", host, port, err)
 	}
+	log.Printf("sent request: \n%s", req)
+	log.Printf("got response: %d", resp.StatusCode)
 
-	log.Printf("Connected to %s (@ %s)", host, conn.RemoteAddr())
-
-	defer conn.Close()
-
-	var reqfields = []string{
-		fmt.Sprintf("%s %s HTTP/1.1", method, path),
-		"Host: " + host,
-		"User-Agent: Roach",
-		"",
-
-		// body would go here, if we had one
+	for _, h := range resp.Headers {
+		fmt.Printf("%s: %s\n", h.Key, h.Value)
 	}
-
-	request := strings.Join(reqfields, "\r\n") + "\r\n"
-
-	if _, err = conn.Write([]byte(request)); err != nil {
-		log.Printf("Error sending request to remote server(%s): %v", ip, err)
+	fmt.Println()
+	if resp.Body == nil {
+		return
 	}
-
-	log.Printf("sent request: \n%s", request)
-
-	for scanner := bufio.NewScanner(conn); scanner.Scan(); {
-		line := scanner.Bytes()
-		if _, err := fmt.Fprintf(os.Stdout, "%s\n", line); err != nil {
-			log.Printf("Error writing to connection: %s", err)
-		}
-		if scanner.Err() != nil {
-			log.Printf("Error reading from connection: %s", err)
-			return
-		}
+	defer resp.Body.Close()
+	if _, err := io.Copy(os.Stdout, resp.Body); err != nil {
+		log.Printf("Error reading response body: %v", err)
 	}
 }
@@ -2,33 +2,92 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"strings"
+	"time"
 )
 
+// maxLineBytes is the longest line echoUpper will buffer; a client sending longer lines is cut off.
+const maxLineBytes = 64 << 10
+
 // echoUpper reads lines from r, uppercases them, and writes them to w.
 func echoUpper(w io.Writer, r io.Reader) {
 	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), maxLineBytes)
 	for scanner.Scan() {
 		line := scanner.Text()
 		// note that scanner.Text() strips the newline character from the end of the line,
 		// so we need to add it back in when we write to w.
 		fmt.Fprintf(w, "%s\n", strings.ToUpper(line))
 	}
-	if err := scanner.Err(); err != nil {
+	if err := scanner.Err(); err == bufio.ErrTooLong {
+		// let the client know why we stopped, rather than just hanging up.
+		fmt.Fprintf(w, "ERROR: line too long (max %d bytes)\n", maxLineBytes)
+		log.Printf("error: line longer than %d bytes; giving up on connection", maxLineBytes)
+	} else if err != nil {
 		log.Printf("error: %s", err)
 	}
 }
 
+// timeouts bound how long a client can take, so a slow or idle one can't hold on to a connection (and its goroutine)
+// forever. Zero means no timeout.
+type timeouts struct {
+	read  time.Duration // to finish sending a line, once it's started
+	write time.Duration // to take each reply
+	idle  time.Duration // to start sending the next line
+}
+
+// deadlineConn applies timeouts to a connection, setting its read or write deadline ahead of every Read or Write.
+type deadlineConn struct {
+	net.Conn
+	timeouts
+	lineStart time.Time // when the line being read started to arrive; zero between lines
+}
+
+func (c *deadlineConn) Read(p []byte) (int, error) {
+	if c.lineStart.IsZero() {
+		c.Conn.SetReadDeadline(deadline(time.Now(), c.idle))
+	} else {
+		c.Conn.SetReadDeadline(deadline(c.lineStart, c.read))
+	}
+	n, err := c.Conn.Read(p)
+	switch {
+	case n == 0:
+	case p[n-1] == '\n':
+		c.lineStart = time.Time{} // waiting for the next line.
+	case c.lineStart.IsZero() || bytes.IndexByte(p[:n], '\n') >= 0:
+		c.lineStart = time.Now() // a new line started.
+	}
+	return n, err
+}
+
+func (c *deadlineConn) Write(p []byte) (int, error) {
+	c.Conn.SetWriteDeadline(deadline(time.Now(), c.write))
+	return c.Conn.Write(p)
+}
+
+// deadline returns the time d after start, or the zero time, i.e. no deadline, if d isn't positive.
+func deadline(start time.Time, d time.Duration) time.Time {
+	if d <= 0 {
+		return time.Time{}
+	}
+	return start.Add(d)
+}
+
 func main() {
 	const name = "rochi"
 	log.SetPrefix("name" + "\t")
 
 	port := flag.Int("p", 8080, "port to listen on")
+	var t timeouts
+	flag.DurationVar(&t.read, "read-timeout", 10*time.Second, "how long a client has to finish sending a line; 0 means no limit")
+	flag.DurationVar(&t.write, "write-timeout", 10*time.Second, "how long a client has to take each reply; 0 means no limit")
+	flag.DurationVar(&t.idle, "idle-timeout", 2*time.Minute, "how long to wait for the next line before hanging up; 0 means no limit")
 	flag.Parse()
 
 	// ListenTCP creates a TCP listener accepting connections on the given address
@@ -55,6 +114,10 @@ func main() {
 		if err != nil {
 			panic(err)
 		}
-		go echoUpper(conn, conn)
+		go func() {
+			defer conn.Close()
+			dc := &deadlineConn{Conn: conn, timeouts: t}
+			echoUpper(dc, dc)
+		}()
 	}
 }